@@ -4,8 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"syscall"
 	"testing"
 	"time"
 
@@ -16,20 +14,20 @@ import (
 type cleanUpFunc func()
 
 func newAuth(t *testing.T) (*Auth, cleanUpFunc) {
-	shutdown := make(chan os.Signal)
 	l, err := zap.NewProduction()
 	if err != nil {
 		t.Fatalf("create logger: %s", err)
 	}
 
-	ks, err := keystore.New(500*time.Millisecond, time.Second, shutdown, l.Sugar())
+	ks, err := keystore.New(500*time.Millisecond, time.Second, l.Sugar())
 	if err != nil {
 		t.Fatalf("create keystore: %s", err)
 	}
 
-	return New(ks), func() {
-		shutdown <- syscall.SIGKILL
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go ks.Rotate(ctx)
+
+	return New(ks), cleanUpFunc(cancel)
 }
 
 func TestAuth(t *testing.T) {
@@ -85,9 +85,12 @@ func (dbase *DB) StatusCheck(ctx context.Context) error {
 
 	var pingError error
 	for attempts := 1; ; attempts++ {
-		pingError = dbase.Ping()
+		pingError = dbase.PingContext(ctx)
 		if pingError == nil {
-			dbase.log.Infow("ping successful", "on attempt", attempts)
+			// Readiness probes check the database periodically, only recoveries are worth a log
+			if attempts > 1 {
+				dbase.log.Infow("ping successful", "on attempt", attempts)
+			}
 			break
 		}
 		wait := time.Duration(attempts) * 100 * time.Millisecond
@@ -142,7 +145,7 @@ func (dbase *DB) namedQueryStruct(ctx context.Context, query string, data, dest
 
 	defer func() {
 		if err != nil {
-			dbase.log.Infow("database.NamedQuerySlice", "query", q, "ERROR", err)
+			web.GetLogger(ctx, dbase.log).Infow("database.NamedQuerySlice", "query", q, "ERROR", err)
 		}
 	}()
 
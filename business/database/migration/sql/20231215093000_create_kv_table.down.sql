@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "kv";
\ No newline at end of file
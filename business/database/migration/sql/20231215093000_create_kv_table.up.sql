@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS "kv" (
+    key         TEXT        PRIMARY KEY,
+    value       BYTEA       NOT NULL,
+    expires_at  TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS kv_expires_at_idx ON "kv" (expires_at) WHERE expires_at IS NOT NULL;
\ No newline at end of file
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/so-heil/wishlist/business/metrics"
 )
 
 type Mail struct {
@@ -95,3 +97,18 @@ func (cc *CourierClient) Send(ctx context.Context, mail Mail) error {
 
 	return nil
 }
+
+// InstrumentedClient records the outcome of every send of the wrapped client
+type InstrumentedClient struct {
+	Client
+}
+
+func NewInstrumentedClient(c Client) *InstrumentedClient {
+	return &InstrumentedClient{c}
+}
+
+func (ic *InstrumentedClient) Send(ctx context.Context, mail Mail) error {
+	err := ic.Client.Send(ctx, mail)
+	metrics.EmailSent(err)
+	return err
+}
@@ -0,0 +1,76 @@
+package i18n
+
+// catalog holds the messages of the non-default locales keyed by problem code with a
+// .title or .detail suffix, parameters are positional such as {0}
+var catalog = map[string]map[string]string{
+	"fa": {
+		"user.email_taken.title":                 "ایمیل قبلا ثبت شده است",
+		"user.email_taken.detail":                "این ایمیل قبلا ثبت شده است",
+		"user.not_found.title":                   "کاربر ثبت نشده است",
+		"user.not_found.detail":                  "این ایمیل ثبت نشده است",
+		"user.wrong_credentials.title":           "اطلاعات ورود اشتباه است",
+		"user.wrong_credentials.detail":          "ایمیل یا رمز عبور اشتباه است",
+		"otp.resend_too_soon.title":              "کد به تازگی ارسال شده است",
+		"otp.resend_too_soon.detail":             "کدی به تازگی ارسال شده است، کمی بعد دوباره تلاش کنید",
+		"otp.invalid_code.title":                 "کد تایید معتبر نیست",
+		"otp.invalid_code.detail":                "کد تایید معتبر نیست",
+		"auth.invalid_token.title":               "توکن معتبر نیست",
+		"auth.invalid_token.detail":              "توکن ارسال شده معتبر نیست",
+		"auth.malformed_token.title":             "هدر Authorization نادرست است",
+		"auth.malformed_token.detail":            "قالب هدر Authorization باید Bearer <token> باشد",
+		"validation.failed.title":                "مقدار برخی فیلدها نامعتبر است",
+		"validation.failed.detail":               "مقدار برخی فیلدها نامعتبر است",
+		"service.unavailable.title":              "برخی سرویس‌ها در دسترس نیستند",
+		"service.unavailable.detail":             "برخی سرویس‌ها در دسترس نیستند",
+		"internal.title":                         "خطای داخلی رخ داد",
+		"internal.detail":                        "مشکلی جدی پیش آمد",
+		"request.unsupported_media_type.detail":  "هدر Content-Type باید application/json باشد",
+		"request.empty_body.detail":              "بدنه درخواست خالی است",
+		"request.body_too_large.detail":          "بدنه درخواست نباید بزرگ‌تر از {0} بایت باشد",
+		"request.malformed_json.detail":          "بدنه درخواست JSON معتبری نیست",
+		"request.invalid_type.detail":            "مقدار فیلد {0} از نوع درستی نیست",
+		"request.unknown_field.detail":           "بدنه درخواست فیلد ناشناخته {0} دارد",
+		"request.trailing_data.detail":           "بدنه درخواست باید فقط یک مقدار JSON داشته باشد",
+		"request.malformed_body.detail":          "بدنه درخواست نامعتبر است",
+		"request.unsupported_version.detail":     "هدر Accept باید یکی از نسخه‌های {0} را بخواهد",
+		"request.route_not_in_version.detail":    "این مسیر در نسخه {0} وجود ندارد",
+		"request.invalid_idempotency_key.detail": "هدر Idempotency-Key باید ۱ تا {0} نویسه قابل چاپ داشته باشد",
+		"request.idempotency_in_progress.detail": "درخواستی با همین Idempotency-Key در حال انجام است",
+		"request.idempotency_key_reused.detail":  "این Idempotency-Key برای درخواستی با بدنه دیگری استفاده شده است",
+	},
+	"fr": {
+		"user.email_taken.title":                 "L'e-mail est déjà enregistré",
+		"user.email_taken.detail":                "cet e-mail est déjà enregistré",
+		"user.not_found.title":                   "L'utilisateur n'est pas enregistré",
+		"user.not_found.detail":                  "cet e-mail n'est pas enregistré",
+		"user.wrong_credentials.title":           "Identifiants incorrects",
+		"user.wrong_credentials.detail":          "l'e-mail ou le mot de passe est incorrect",
+		"otp.resend_too_soon.title":              "Un code a été envoyé récemment",
+		"otp.resend_too_soon.detail":             "un code a été envoyé récemment, réessayez plus tard",
+		"otp.invalid_code.title":                 "Le code de vérification n'est pas valide",
+		"otp.invalid_code.detail":                "le code de vérification n'est pas valide",
+		"auth.invalid_token.title":               "Le jeton n'est pas valide",
+		"auth.invalid_token.detail":              "le jeton fourni n'est pas valide",
+		"auth.malformed_token.title":             "L'en-tête Authorization est mal formé",
+		"auth.malformed_token.detail":            "l'en-tête Authorization doit être de la forme Bearer <token>",
+		"validation.failed.title":                "Certains champs ont des valeurs invalides",
+		"validation.failed.detail":               "certains champs ont des valeurs invalides",
+		"service.unavailable.title":              "Certains services ne sont pas disponibles",
+		"service.unavailable.detail":             "certains services ne sont pas disponibles",
+		"internal.title":                         "Une erreur interne est survenue",
+		"internal.detail":                        "quelque chose s'est très mal passé",
+		"request.unsupported_media_type.detail":  "l'en-tête Content-Type doit être application/json",
+		"request.empty_body.detail":              "le corps de la requête est vide",
+		"request.body_too_large.detail":          "le corps de la requête ne doit pas dépasser {0} octets",
+		"request.malformed_json.detail":          "le corps de la requête n'est pas un JSON valide",
+		"request.invalid_type.detail":            "le champ {0} n'a pas le bon type",
+		"request.unknown_field.detail":           "le corps de la requête contient le champ inconnu {0}",
+		"request.trailing_data.detail":           "le corps de la requête ne doit contenir qu'une seule valeur JSON",
+		"request.malformed_body.detail":          "le corps de la requête est mal formé",
+		"request.unsupported_version.detail":     "l'en-tête Accept doit demander l'une des versions {0}",
+		"request.route_not_in_version.detail":    "la route n'est pas disponible dans la version {0}",
+		"request.invalid_idempotency_key.detail": "l'en-tête Idempotency-Key doit contenir de 1 à {0} caractères imprimables",
+		"request.idempotency_in_progress.detail": "une requête avec la même Idempotency-Key est en cours de traitement",
+		"request.idempotency_key_reused.detail":  "cette Idempotency-Key a été utilisée pour une requête avec un autre corps",
+	},
+}
@@ -0,0 +1,131 @@
+// Package i18n holds the translation catalog of the application, validation messages and
+// business error messages of every supported locale live on the same universal translator
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fa"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"golang.org/x/text/language"
+)
+
+// Default is the locale of messages written in code, it is used when the client accepts
+// none of the supported locales
+const Default = "en"
+
+var (
+	supported = []language.Tag{language.English, language.Persian, language.French}
+	matcher   = language.NewMatcher(supported)
+)
+
+var (
+	once    sync.Once
+	initErr error
+	uni     *ut.UniversalTranslator
+
+	// arity is the number of parameters of each catalog entry by locale, the translator
+	// panics when given less parameters than an entry has
+	arity       = make(map[string]map[string]int)
+	placeholder = regexp.MustCompile(`\{[0-9]+\}`)
+)
+
+// Init registers the supported locales and the catalog, it is safe to call more than once
+func Init() error {
+	once.Do(func() {
+		translators := []locales.Translator{en.New(), fa.New(), fr.New()}
+		uni = ut.New(translators[0], translators...)
+
+		for locale, messages := range catalog {
+			trans, found := uni.GetTranslator(locale)
+			if !found {
+				initErr = fmt.Errorf("cannot find %s translator", locale)
+				return
+			}
+			arity[locale] = make(map[string]int, len(messages))
+			for key, text := range messages {
+				arity[locale][key] = len(placeholder.FindAllString(text, -1))
+				if err := trans.Add(key, text, false); err != nil {
+					initErr = fmt.Errorf("add %s translation %q: %w", locale, key, err)
+					return
+				}
+			}
+		}
+	})
+	return initErr
+}
+
+// Locales returns the supported locales, the default first
+func Locales() []string {
+	names := make([]string, len(supported))
+	for i, tag := range supported {
+		names[i] = tag.String()
+	}
+	return names
+}
+
+// Match returns the supported locale the Accept-Language header prefers, e.g. fa for fa-IR
+func Match(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return supported[index].String()
+}
+
+// Translator returns the translator of the locale, or the default one for unknown locales.
+// Init must have been called.
+func Translator(locale string) ut.Translator {
+	trans, _ := uni.GetTranslator(locale)
+	return trans
+}
+
+type ctxKey int
+
+const localeKey ctxKey = 1
+
+// WithLocale returns a copy of ctx carrying the locale of the request
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// Locale returns the locale carried by ctx or the default one
+func Locale(ctx context.Context) string {
+	locale, ok := ctx.Value(localeKey).(string)
+	if !ok {
+		return Default
+	}
+	return locale
+}
+
+// T translates the catalog entry for the locale of ctx, false is returned for the default
+// locale as its messages are the ones in code, or when the locale has no such entry or
+// the entry needs more parameters than given
+func T(ctx context.Context, key string, params ...string) (string, bool) {
+	locale := Locale(ctx)
+	if locale == Default || uni == nil {
+		return "", false
+	}
+
+	n, ok := arity[locale][key]
+	if !ok || n > len(params) {
+		return "", false
+	}
+
+	text, err := Translator(locale).T(key, params...)
+	if err != nil {
+		return "", false
+	}
+	return text, true
+}
@@ -0,0 +1,50 @@
+package i18n_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/so-heil/wishlist/business/i18n"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		locale         string
+	}{
+		{acceptLanguage: "", locale: "en"},
+		{acceptLanguage: "fr", locale: "fr"},
+		{acceptLanguage: "fa-IR,en;q=0.5", locale: "fa"},
+		{acceptLanguage: "de;q=0.9, fr;q=0.5", locale: "fr"},
+		{acceptLanguage: "de", locale: "en"},
+		{acceptLanguage: "*", locale: "en"},
+		{acceptLanguage: "not a language;;", locale: "en"},
+	}
+
+	for _, tt := range tests {
+		if got := i18n.Match(tt.acceptLanguage); got != tt.locale {
+			t.Errorf("Match(%q) want %q got %q", tt.acceptLanguage, tt.locale, got)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if err := i18n.Init(); err != nil {
+		t.Fatalf("init: %s", err)
+	}
+
+	if _, ok := i18n.T(context.Background(), "user.email_taken.detail"); ok {
+		t.Error("should not translate to the default locale")
+	}
+
+	fr := i18n.WithLocale(context.Background(), "fr")
+	if got, ok := i18n.T(fr, "request.body_too_large.detail", "32"); !ok || got != "le corps de la requête ne doit pas dépasser 32 octets" {
+		t.Errorf("should translate with params, got %q", got)
+	}
+	if _, ok := i18n.T(fr, "request.body_too_large.detail"); ok {
+		t.Error("should not translate without the params of the entry")
+	}
+	if _, ok := i18n.T(fr, "missing.detail"); ok {
+		t.Error("should not translate missing entries")
+	}
+}
@@ -1,17 +1,19 @@
 package keystore
 
 import (
+	"context"
 	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
 	"errors"
 	"fmt"
-	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/so-heil/wishlist/business/metrics"
 	"go.uber.org/zap"
 )
 
@@ -22,40 +24,84 @@ type Key struct {
 	Signer crypto.Signer
 }
 
+// ErrorHandler is called with the critical errors of the keystore, failed rotations are
+// reported as *RotationError
+type ErrorHandler func(err error)
+
+// KeyGenerator generates a signing key and its id
+type KeyGenerator func() (string, crypto.Signer, error)
+
+// RotationError reports a failed rotation, the active key stays in use until it expires
+type RotationError struct {
+	Round int
+	// Consecutive counts the rotations failed in a row, including this one
+	Consecutive int
+	Err         error
+}
+
+func (re *RotationError) Error() string {
+	return fmt.Sprintf("#%d keystore rotation: failed: %s", re.Round, re.Err)
+}
+
+func (re *RotationError) Unwrap() error {
+	return re.Err
+}
+
 type KeyStore struct {
 	store            sync.Map
 	rotationPeriod   time.Duration
 	expirationPeriod time.Duration
-	shutdown         <-chan os.Signal
-	critErrs         chan<- error
+	onError          ErrorHandler
+	genKey           KeyGenerator
 	logger           *zap.SugaredLogger
-	active           string
+	active           atomic.Pointer[string]
 	SigningMethod    jwt.SigningMethod
 }
 
-// New creates a new keystore with one initial key, and starts key rotation
+// Option configures optional behaviour of the KeyStore
+type Option func(*KeyStore)
+
+// WithErrorHandler sets the handler of critical errors, they are only logged by default
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(ks *KeyStore) {
+		ks.onError = h
+	}
+}
+
+// WithKeyGenerator replaces the ed25519 key generator, e.g. to simulate failures in tests
+func WithKeyGenerator(gen KeyGenerator) Option {
+	return func(ks *KeyStore) {
+		ks.genKey = gen
+	}
+}
+
+// New creates a new keystore with one initial key, keys are rotated by Rotate
 // When having multiple instances of the consumer application, keystore can exist on another service
 func New(
 	rotationPeriod time.Duration,
 	expirationPeriod time.Duration,
-	shutdown chan os.Signal,
 	logger *zap.SugaredLogger,
+	opts ...Option,
 ) (*KeyStore, error) {
 	ks := &KeyStore{
 		rotationPeriod:   rotationPeriod,
 		expirationPeriod: expirationPeriod,
-		shutdown:         shutdown,
+		genKey:           genKey,
 		logger:           logger,
 		SigningMethod:    jwt.SigningMethodEdDSA,
 	}
+	ks.onError = func(err error) {
+		ks.logger.Errorw("keystore: critical error", "ERROR", err)
+	}
+	for _, opt := range opts {
+		opt(ks)
+	}
 
 	// Init store with an initial key
 	if err := ks.addKey(); err != nil {
 		return nil, fmt.Errorf("add init key to store: %w", err)
 	}
 
-	ks.startRotation()
-
 	return ks, nil
 }
 
@@ -67,53 +113,71 @@ func (ks *KeyStore) Signer(id string) (Key, error) {
 	key, ok := val.(Key)
 	if !ok {
 		err := fmt.Errorf("cannot assign map value to keystore key, map value: %v", val)
-		ks.critErrs <- err
+		ks.onError(err)
 		return Key{}, err
 	}
 	return key, nil
 }
 
 func (ks *KeyStore) Active() (string, Key, error) {
-	active := ks.active
+	active := *ks.active.Load()
 	sig, err := ks.Signer(active)
 	return active, sig, err
 }
 
+// StatusCheck reports whether the active key can be used for signing
+func (ks *KeyStore) StatusCheck(ctx context.Context) error {
+	id, key, err := ks.Active()
+	if err != nil {
+		return fmt.Errorf("active key %q: %w", id, err)
+	}
+	if key.Expire.Before(time.Now()) {
+		return fmt.Errorf("active key %q expired at %s", id, key.Expire)
+	}
+	return nil
+}
+
 // Revoke is accessible to revoke keys when compromised
 func (ks *KeyStore) Revoke(id string) {
 	ks.store.Delete(id)
 }
 
-func (ks *KeyStore) startRotation() {
+// Rotate adds a new key every rotation period and revokes the expired ones until ctx is
+// canceled, it is meant to run in its own goroutine. Failed rotations are reported to the
+// error handler and retried on the next period.
+func (ks *KeyStore) Rotate(ctx context.Context) error {
 	ticker := time.NewTicker(ks.rotationPeriod)
-	go func() {
-		defer ticker.Stop()
-		ks.logger.Infow("keystore rotation: starting", "rotation period", ks.rotationPeriod, "first rotation", time.Now().Add(ks.rotationPeriod))
-		var round int
-		for {
-			select {
-			case <-ks.shutdown:
-				ks.logger.Infow("keystore rotation: shutting down", "rotations done", round)
-				return
-			case <-ticker.C:
-				round++
-				ks.logger.Infow("keystore rotation: starting", "round", round)
-				expiredCount, err := ks.rotate()
-				if err != nil {
-					ks.critErrs <- fmt.Errorf("#%d keystore rotation: failed: %s", round, err)
-				}
-				ks.logger.Infow(
-					"keystore rotation: successful",
-					"expired in round",
-					expiredCount,
-					"round",
-					round,
-					"next rotation",
-					time.Now().Add(ks.rotationPeriod),
-				)
+	defer ticker.Stop()
+
+	ks.logger.Infow("keystore rotation: starting", "rotation period", ks.rotationPeriod, "first rotation", time.Now().Add(ks.rotationPeriod))
+	var round, failed int
+	for {
+		select {
+		case <-ctx.Done():
+			ks.logger.Infow("keystore rotation: shutting down", "rotations done", round)
+			return nil
+		case <-ticker.C:
+			round++
+			ks.logger.Infow("keystore rotation: starting", "round", round)
+			expiredCount, err := ks.rotate()
+			metrics.KeyRotated(err)
+			if err != nil {
+				failed++
+				ks.onError(&RotationError{Round: round, Consecutive: failed, Err: err})
+				continue
 			}
+			failed = 0
+			ks.logger.Infow(
+				"keystore rotation: successful",
+				"expired in round",
+				expiredCount,
+				"round",
+				round,
+				"next rotation",
+				time.Now().Add(ks.rotationPeriod),
+			)
 		}
-	}()
+	}
 }
 
 func (ks *KeyStore) rotate() (int, error) {
@@ -150,7 +214,7 @@ func (ks *KeyStore) rotate() (int, error) {
 }
 
 func (ks *KeyStore) addKey() error {
-	id, newKey, err := genKey()
+	id, newKey, err := ks.genKey()
 	if err != nil {
 		return fmt.Errorf("generate new key: %w", err)
 	}
@@ -158,7 +222,7 @@ func (ks *KeyStore) addKey() error {
 		Expire: time.Now().Add(ks.expirationPeriod),
 		Signer: newKey,
 	})
-	ks.active = id
+	ks.active.Store(&id)
 	return nil
 }
 
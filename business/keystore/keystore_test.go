@@ -1,9 +1,13 @@
 package keystore_test
 
 import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
 	"errors"
-	"os"
-	"syscall"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,7 +17,6 @@ import (
 )
 
 func TestKeyStore(t *testing.T) {
-	shutdown := make(chan os.Signal, 1)
 	logger, err := zap.NewProduction()
 	if err != nil {
 		t.Fatal(err)
@@ -22,15 +25,25 @@ func TestKeyStore(t *testing.T) {
 	rotationPeriod := 100 * time.Millisecond
 	expirationPeriod := 2 * time.Second
 	tolerance := 50 * time.Millisecond
-	ks, err := keystore.New(rotationPeriod, expirationPeriod, shutdown, l)
+	ks, err := keystore.New(rotationPeriod, expirationPeriod, l)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rotating := make(chan error, 1)
+	go func() {
+		rotating <- ks.Rotate(ctx)
+	}()
+
 	activeId, key, err := ks.Active()
 	if err != nil {
 		t.Fatalf("keystore should return an active key after init: %s", err)
 	}
+	if err := ks.StatusCheck(context.Background()); err != nil {
+		t.Errorf("status check should pass with an active key: %s", err)
+	}
 
 	type TestClaims struct {
 		ID string `json:"ID,omitempty"`
@@ -109,7 +122,10 @@ func TestKeyStore(t *testing.T) {
 	if err != nil {
 		t.Fatal("should get active key: %w", err)
 	}
-	shutdown <- syscall.SIGTERM
+	cancel()
+	if err := <-rotating; err != nil {
+		t.Errorf("rotation should stop cleanly, got: %s", err)
+	}
 	// Wait for another rotation period
 	time.Sleep(rotationPeriod + tolerance)
 	afterSt, _, err := ks.Active()
@@ -121,3 +137,98 @@ func TestKeyStore(t *testing.T) {
 		t.Errorf("should have stopped rotation")
 	}
 }
+
+func TestRotationFailure(t *testing.T) {
+	var (
+		calls   atomic.Int64
+		failing atomic.Bool
+	)
+	errGen := errors.New("entropy exhausted")
+	gen := func() (string, crypto.Signer, error) {
+		if failing.Load() {
+			return "", nil, errGen
+		}
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return fmt.Sprintf("key-%d", calls.Add(1)), priv, err
+	}
+
+	errs := make(chan error, 10)
+	ks, err := keystore.New(20*time.Millisecond, time.Minute, zap.NewNop().Sugar(),
+		keystore.WithKeyGenerator(gen),
+		keystore.WithErrorHandler(func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("create keystore: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ks.Rotate(ctx)
+
+	next := func() *keystore.RotationError {
+		t.Helper()
+		select {
+		case err := <-errs:
+			var re *keystore.RotationError
+			if !errors.As(err, &re) || !errors.Is(err, errGen) {
+				t.Fatalf("should report a rotation error wrapping the cause, got: %v", err)
+			}
+			return re
+		case <-time.After(time.Second):
+			t.Fatal("should report the failed rotation")
+			return nil
+		}
+	}
+
+	failing.Store(true)
+	for want := 1; want <= 2; want++ {
+		if re := next(); re.Consecutive != want {
+			t.Errorf("consecutive failures want %d got %d", want, re.Consecutive)
+		}
+	}
+
+	if id, _, err := ks.Active(); err != nil || id != "key-1" {
+		t.Errorf("should keep the initial key active while rotations fail, got %q: %v", id, err)
+	}
+
+	failing.Store(false)
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls.Load() < 2 {
+		t.Fatal("should rotate again once the generator recovers")
+	}
+
+	// Failures reported before the recovery are not of interest
+	for len(errs) > 0 {
+		<-errs
+	}
+	failing.Store(true)
+	if re := next(); re.Consecutive != 1 {
+		t.Errorf("a successful rotation should reset consecutive failures, got: %d", re.Consecutive)
+	}
+}
+
+func TestStatusCheckWhileRotating(t *testing.T) {
+	ks, err := keystore.New(time.Millisecond, time.Minute, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("create keystore: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ks.Rotate(ctx)
+
+	// Probes check the active key while it is rotated, run with -race
+	for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); {
+		if err := ks.StatusCheck(context.Background()); err != nil {
+			t.Fatalf("status check should pass while rotating: %s", err)
+		}
+	}
+}
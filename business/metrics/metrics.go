@@ -0,0 +1,130 @@
+// Package metrics holds the prometheus collectors of the application and serves them in the
+// prometheus text format, collectors are registered on a registry of their own so only
+// application metrics, go runtime and process metrics are exposed
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wishapi"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of handled requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of handled requests by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	inFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "Number of requests being handled by route template.",
+	}, []string{"route"})
+
+	keyRotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "keystore",
+		Name:      "rotations_total",
+		Help:      "Number of keystore rotations by result.",
+	}, []string{"result"})
+
+	keyStoreErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "keystore",
+		Name:      "critical_errors_total",
+		Help:      "Number of critical keystore errors by kind.",
+	}, []string{"kind"})
+
+	emails = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "email",
+		Name:      "sends_total",
+		Help:      "Number of email sends by outcome.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests,
+		requestDuration,
+		inFlight,
+		keyRotations,
+		keyStoreErrors,
+		emails,
+	)
+}
+
+// Handler serves the registered metrics in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exposes the connection pool stats of the database under the name
+func RegisterDB(db *sql.DB, name string) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RequestStarted marks a request on the route as in flight, the returned function records
+// its completion with the final status code
+func RequestStarted(method, route string, start time.Time) func(status int) {
+	g := inFlight.WithLabelValues(route)
+	g.Inc()
+
+	return func(status int) {
+		g.Dec()
+		code := strconv.Itoa(status)
+		requests.WithLabelValues(method, route, code).Inc()
+		requestDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// KeyRotated records the result of a keystore rotation
+func KeyRotated(err error) {
+	keyRotations.WithLabelValues(result(err)).Inc()
+}
+
+// KeyStoreError records a critical keystore error of the kind, e.g. rotation
+func KeyStoreError(kind string) {
+	keyStoreErrors.WithLabelValues(kind).Inc()
+}
+
+// EmailSent records the outcome of sending an email
+func EmailSent(err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		emails.WithLabelValues("timeout").Inc()
+	default:
+		emails.WithLabelValues(result(err)).Inc()
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -6,26 +6,59 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"strconv"
 	"text/template"
 	"time"
 
 	"github.com/so-heil/wishlist/business/storage/keyvalue"
 )
 
-var ErrInvalidCode = errors.New("verification code is not valid")
+var (
+	ErrInvalidCode = errors.New("verification code is not valid")
+	ErrCodeExists  = errors.New("a code has been generated for identity")
+)
+
+// Issued describes a code created for an identity
+type Issued struct {
+	Code     string
+	IssuedAt time.Time
+	// ExpiresIn is the time the code stays valid
+	ExpiresIn time.Duration
+	// ResendAfter is the time until another code can be created for the identity
+	ResendAfter time.Duration
+}
 
+// OTP keeps codes and resend cooldowns under separate keys, a code may outlive its
+// cooldown in which case creating a new code replaces it
 type OTP struct {
-	s          keyvalue.KeyValueStore
+	codes      keyvalue.KeyValueStore
+	cooldowns  keyvalue.KeyValueStore
 	codeLen    int
 	expiration time.Duration
+	cooldown   time.Duration
 	templ      *template.Template
 }
 
-func New(s keyvalue.KeyValueStore, codeLen int, expiration time.Duration, templ *template.Template) *OTP {
+// minCooldown is the shortest cooldown stores keep an expiry for, shorter ones would be
+// stored without expiration and lock the identity out for good
+const minCooldown = time.Second
+
+// New creates codes valid for expiration, the cooldown is clamped to at least a second
+// and at most the expiration
+func New(s keyvalue.KeyValueStore, codeLen int, expiration, cooldown time.Duration, templ *template.Template) *OTP {
+	if cooldown > expiration {
+		cooldown = expiration
+	}
+	if cooldown < minCooldown {
+		cooldown = minCooldown
+	}
+
 	return &OTP{
-		s:          s,
+		codes:      keyvalue.Namespace(s, "code"),
+		cooldowns:  keyvalue.Namespace(s, "cooldown"),
 		codeLen:    codeLen,
 		expiration: expiration,
+		cooldown:   cooldown,
 		templ:      templ,
 	}
 }
@@ -38,8 +71,10 @@ func (o OTP) Message(code string) (string, error) {
 	return buf.String(), nil
 }
 
+// Check consumes the code of the identity if it matches, a code can only be consumed once
+// even by concurrent checks while mismatching codes leave it in place
 func (o *OTP) Check(identity, code string) error {
-	toMatch, err := o.s.Get(identity)
+	toMatch, err := o.codes.Get(identity)
 	if err != nil {
 		if errors.Is(err, keyvalue.ErrNotFound) {
 			return ErrInvalidCode
@@ -51,19 +86,72 @@ func (o *OTP) Check(identity, code string) error {
 		return ErrInvalidCode
 	}
 
-	o.s.Del(identity)
+	consumed, err := o.codes.GetDel(identity)
+	if err != nil {
+		if errors.Is(err, keyvalue.ErrNotFound) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	// The code might have been replaced between Get and GetDel
+	if string(consumed) != code {
+		return ErrInvalidCode
+	}
+
 	return nil
 }
 
-func (o *OTP) Exists(identity string) (bool, error) {
-	_, err := o.s.Get(identity)
+// Create generates and stores a code for the identity unless it is in its resend cooldown,
+// in which case ErrCodeExists is returned
+func (o *OTP) Create(identity string) (Issued, error) {
+	now := time.Now()
+	ok, err := o.cooldowns.SetNX(identity, []byte(strconv.FormatInt(now.Unix(), 10)), o.cooldown)
+	if err != nil {
+		return Issued{}, fmt.Errorf("setnx cooldown: %w", err)
+	}
+	if !ok {
+		return Issued{}, ErrCodeExists
+	}
+
+	code, err := o.GenCode()
+	if err != nil {
+		o.cooldowns.Del(identity)
+		return Issued{}, err
+	}
+
+	if err := o.codes.Set(identity, []byte(code), o.expiration); err != nil {
+		o.cooldowns.Del(identity)
+		return Issued{}, fmt.Errorf("set code: %w", err)
+	}
+
+	return Issued{
+		Code:        code,
+		IssuedAt:    now,
+		ExpiresIn:   o.expiration,
+		ResendAfter: o.cooldown,
+	}, nil
+}
+
+// Cooldown returns the time left until a new code can be created for the identity
+func (o *OTP) Cooldown(identity string) (time.Duration, error) {
+	left, err := o.cooldowns.TTL(identity)
 	if err != nil {
 		if errors.Is(err, keyvalue.ErrNotFound) {
-			return false, nil
+			return 0, nil
 		}
-		return false, err
+		return 0, fmt.Errorf("cooldown ttl: %w", err)
+	}
+	if left == keyvalue.NoExpiration {
+		return o.cooldown, nil
 	}
-	return true, nil
+	return left, nil
+}
+
+// Revoke removes the code of the identity and its cooldown, e.g. when it could not be delivered
+func (o *OTP) Revoke(identity string) {
+	o.codes.Del(identity)
+	o.cooldowns.Del(identity)
 }
 
 var table = [...]byte{'1', '2', '3', '4', '5', '6', '7', '8', '9', '0'}
@@ -81,10 +169,3 @@ func (o *OTP) GenCode() (string, error) {
 
 	return string(r), nil
 }
-
-func (o *OTP) Save(identity, code string) error {
-	if err := o.s.Set(identity, []byte(code), o.expiration); err != nil {
-		return fmt.Errorf("set keyvalue: %w", err)
-	}
-	return nil
-}
@@ -16,58 +16,76 @@ func TestOTP(t *testing.T) {
 		t.Fatalf("create otp template: %s", err)
 	}
 
-	otp := New(kvstores.NewFreeCache(1024*100), 8, 5*time.Second, templ)
+	otp := New(kvstores.NewFreeCache(1024*100), 8, 3*time.Second, time.Second, templ)
 
-	code, err := otp.GenCode()
+	identity := "some_user"
+	issued, err := otp.Create(identity)
 	if err != nil {
-		t.Fatal("code should be generated", err)
+		t.Fatal("code should be created", err)
 	}
-
-	identity := "some_user"
-	if err := otp.Save(identity, code); err != nil {
-		t.Fatal("code should be saved", err)
+	if issued.ExpiresIn != 3*time.Second || issued.ResendAfter != time.Second {
+		t.Errorf("issued code should report expiration and cooldown, got: %+v", issued)
 	}
 
-	exists, err := otp.Exists(identity)
-	if err != nil {
-		t.Fatal("should report if identity exists", err)
-	}
-	if !exists {
-		t.Fatal("code should exist in otp store")
+	if _, err := otp.Create(identity); !errors.Is(err, ErrCodeExists) {
+		t.Fatalf("should not create another code during cooldown, got: %v", err)
 	}
 
-	time.Sleep(6 * time.Second)
-	stillExists, err := otp.Exists(identity)
+	cooldown, err := otp.Cooldown(identity)
 	if err != nil {
-		t.Fatal("should report if identity exists", err)
+		t.Fatal("should report cooldown", err)
 	}
-	if stillExists {
-		t.Fatal("code should be expired")
+	if cooldown <= 0 || cooldown > time.Second {
+		t.Errorf("cooldown should be within a second, got: %s", cooldown)
 	}
 
-	newCode, err := otp.GenCode()
+	// Cooldown passes before the code expires, a new code replaces the old one
+	time.Sleep(1500 * time.Millisecond)
+	reissued, err := otp.Create(identity)
 	if err != nil {
-		t.Fatal("code should be generated", err)
+		t.Fatal("code should be created after cooldown", err)
 	}
 
-	if newCode == code {
+	if reissued.Code == issued.Code {
 		t.Error("should generate different codes")
 	}
-
-	if err := otp.Save(identity, newCode); err != nil {
-		t.Fatal("code should be saved", err)
+	if err := otp.Check(identity, issued.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Error("replaced code should not be valid")
 	}
 
-	if err := otp.Check(identity, "123112"); err != nil {
-		if !errors.Is(err, ErrInvalidCode) {
-			t.Error("should yield invalid code")
-		}
+	if err := otp.Check(identity, "123112"); !errors.Is(err, ErrInvalidCode) {
+		t.Error("should yield invalid code")
 	}
 
+	newCode := reissued.Code
 	if err := otp.Check(identity, newCode); err != nil {
 		t.Error("should validate correct code")
 	}
 
+	if err := otp.Check(identity, newCode); !errors.Is(err, ErrInvalidCode) {
+		t.Error("code should be consumed after a successful check")
+	}
+
+	// Revoke clears the cooldown as well
+	otp.Revoke(identity)
+	revoked, err := otp.Create(identity)
+	if err != nil {
+		t.Fatal("code should be created after revoke", err)
+	}
+	otp.Revoke(identity)
+	if err := otp.Check(identity, revoked.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Error("revoked code should not be valid")
+	}
+
+	expiring, err := otp.Create(identity)
+	if err != nil {
+		t.Fatal("code should be created", err)
+	}
+	time.Sleep(4 * time.Second)
+	if err := otp.Check(identity, expiring.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Error("code should be expired")
+	}
+
 	buf := new(bytes.Buffer)
 	if err := templ.Execute(buf, newCode); err != nil {
 		t.Errorf("should execute template: %s", err)
@@ -82,3 +100,49 @@ func TestOTP(t *testing.T) {
 		t.Error("message should be same as executing template")
 	}
 }
+
+func TestCooldownBounds(t *testing.T) {
+	templ := template.Must(template.New("otp").Parse(`{{.}}`))
+
+	tests := []struct {
+		name       string
+		expiration time.Duration
+		cooldown   time.Duration
+		want       time.Duration
+	}{
+		{name: "zero", expiration: time.Minute, cooldown: 0, want: time.Second},
+		{name: "subSecond", expiration: time.Minute, cooldown: 300 * time.Millisecond, want: time.Second},
+		{name: "overExpiration", expiration: 30 * time.Second, cooldown: time.Minute, want: 30 * time.Second},
+		{name: "valid", expiration: time.Minute, cooldown: 10 * time.Second, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := kvstores.NewFreeCache(1024 * 100)
+			otp := New(store, 6, tt.expiration, tt.cooldown, templ)
+
+			issued, err := otp.Create("some_user")
+			if err != nil {
+				t.Fatalf("code should be created: %s", err)
+			}
+			if issued.ResendAfter != tt.want {
+				t.Errorf("resend after want %s got %s", tt.want, issued.ResendAfter)
+			}
+
+			left, err := otp.Cooldown("some_user")
+			if err != nil {
+				t.Fatalf("should report cooldown: %s", err)
+			}
+			if left <= 0 || left > tt.want {
+				t.Errorf("cooldown should expire within %s, got: %s", tt.want, left)
+			}
+
+			if tt.cooldown == 0 {
+				time.Sleep(tt.want + 100*time.Millisecond)
+				if _, err := otp.Create("some_user"); err != nil {
+					t.Errorf("should create a code once the cooldown passed: %s", err)
+				}
+			}
+		})
+	}
+}
@@ -6,11 +6,25 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("key is not present in store")
+	ErrNotFound   = errors.New("key is not present in store")
+	ErrNotInteger = errors.New("value is not an integer")
 )
 
+// NoExpiration is reported by TTL for keys that are stored without an expiration
+const NoExpiration time.Duration = -1
+
 type KeyValueStore interface {
 	Set(key string, data []byte, expire time.Duration) error
 	Get(key string) ([]byte, error)
 	Del(key string) bool
+	// SetNX sets the key only if it is not present and reports whether it has been set
+	SetNX(key string, data []byte, expire time.Duration) (bool, error)
+	// Incr increments the decimal integer stored at key and returns the new value,
+	// absent keys start from zero and are stored with the passed expiration while
+	// present keys keep their own
+	Incr(key string, expire time.Duration) (int64, error)
+	// TTL returns the time left to live for the key, or NoExpiration
+	TTL(key string) (time.Duration, error)
+	// GetDel gets the value of the key and deletes it, only one of the concurrent callers gets the value
+	GetDel(key string) ([]byte, error)
 }
@@ -0,0 +1,84 @@
+package keyvalue_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
+)
+
+func TestNamespace(t *testing.T) {
+	store := kvstores.NewFreeCache(1024 * 100)
+	otps := keyvalue.Namespace(store, "otp")
+	sessions := keyvalue.Namespace(store, "sessions")
+
+	if err := otps.Set("test@test.com", []byte("123456"), time.Minute); err != nil {
+		t.Fatalf("should set key: %s", err)
+	}
+	if _, err := sessions.Get("test@test.com"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("namespaces should not share keys, got: %v", err)
+	}
+
+	raw, err := store.Get("otp:test@test.com")
+	if err != nil || string(raw) != "123456" {
+		t.Errorf("key should be prefixed in underlying store, got %q, err: %v", raw, err)
+	}
+
+	nested := keyvalue.Namespace(otps, "resend")
+	if _, err := nested.Incr("test@test.com", time.Minute); err != nil {
+		t.Fatalf("should incr: %s", err)
+	}
+	if _, err := store.Get("otp:resend:test@test.com"); err != nil {
+		t.Errorf("nested namespaces should be joined, err: %v", err)
+	}
+
+	v, err := otps.GetDel("test@test.com")
+	if err != nil || string(v) != "123456" {
+		t.Errorf("should get and delete through namespace, got %q, err: %v", v, err)
+	}
+}
+
+func TestTyped(t *testing.T) {
+	type session struct {
+		UserID int      `json:"user_id"`
+		Roles  []string `json:"roles"`
+	}
+
+	codecs := map[string]keyvalue.Codec{
+		"json": keyvalue.JSONCodec{},
+		"gob":  keyvalue.GobCodec{},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			store := keyvalue.NewTyped[session](kvstores.NewFreeCache(1024*100), codec)
+
+			want := session{UserID: 7, Roles: []string{"admin"}}
+			if err := store.Set("s", want, time.Minute); err != nil {
+				t.Fatalf("should set value: %s", err)
+			}
+
+			got, err := store.Get("s")
+			if err != nil {
+				t.Fatalf("should get value: %s", err)
+			}
+			if got.UserID != want.UserID || len(got.Roles) != 1 || got.Roles[0] != "admin" {
+				t.Errorf("value want %+v got %+v", want, got)
+			}
+
+			ok, err := store.SetNX("s", session{}, time.Minute)
+			if err != nil || ok {
+				t.Errorf("should not overwrite present value, ok: %t, err: %v", ok, err)
+			}
+
+			if _, err := store.GetDel("s"); err != nil {
+				t.Errorf("should get and delete value: %s", err)
+			}
+			if _, err := store.Get("s"); !errors.Is(err, keyvalue.ErrNotFound) {
+				t.Errorf("value should be deleted, got: %v", err)
+			}
+		})
+	}
+}
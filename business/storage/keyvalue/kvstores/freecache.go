@@ -2,23 +2,48 @@ package kvstores
 
 import (
 	"errors"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/coocood/freecache"
 	"github.com/so-heil/wishlist/business/storage/keyvalue"
 )
 
+// freecache keeps at least 512 KiB in 256 segments and stores entries up to a quarter of
+// a segment including a 24 byte header, see freecache.ErrLargeEntry
+const (
+	freecacheMinSize     = 512 << 10
+	freecacheEntryHeader = 24
+)
+
 type FreeCache struct {
-	fc *freecache.Cache
+	fc       *freecache.Cache
+	maxEntry int
+	// mu guards the writes so the read-modify-write operations that freecache cannot do
+	// in one call, Incr and GetDel, are atomic
+	mu sync.Mutex
 }
 
 func NewFreeCache(size int) *FreeCache {
 	fc := freecache.NewCache(size)
-	return &FreeCache{fc}
+	return &FreeCache{
+		fc:       fc,
+		maxEntry: max(size, freecacheMinSize)/1024 - freecacheEntryHeader,
+	}
+}
+
+// MaxEntrySize is the largest length of a key and its value stored together, about 1/1024
+// of the size of the cache, setting larger entries fails
+func (fc *FreeCache) MaxEntrySize() int {
+	return fc.maxEntry
 }
 
 func (fc *FreeCache) Set(key string, data []byte, expire time.Duration) error {
-	return fc.fc.Set([]byte(key), data, int(expire.Seconds()))
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return fc.fc.Set([]byte(key), data, seconds(expire))
 }
 
 func (fc *FreeCache) Get(key string) ([]byte, error) {
@@ -33,5 +58,91 @@ func (fc *FreeCache) Get(key string) ([]byte, error) {
 }
 
 func (fc *FreeCache) Del(key string) bool {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
 	return fc.fc.Del([]byte(key))
 }
+
+func (fc *FreeCache) SetNX(key string, data []byte, expire time.Duration) (bool, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	// GetOrSet returns a nil value when it has set the key
+	existing, err := fc.fc.GetOrSet([]byte(key), data, seconds(expire))
+	if err != nil {
+		return false, err
+	}
+	return existing == nil, nil
+}
+
+func (fc *FreeCache) Incr(key string, expire time.Duration) (int64, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	expireSeconds := seconds(expire)
+	var n int64
+
+	val, expireAt, err := fc.fc.GetWithExpiration([]byte(key))
+	switch {
+	case errors.Is(err, freecache.ErrNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		n, err = strconv.ParseInt(string(val), 10, 64)
+		if err != nil {
+			return 0, keyvalue.ErrNotInteger
+		}
+		expireSeconds = 0
+		if expireAt != 0 {
+			expireSeconds = int(int64(expireAt) - time.Now().Unix())
+			if expireSeconds <= 0 {
+				expireSeconds = 1
+			}
+		}
+	}
+
+	n++
+	if err := fc.fc.Set([]byte(key), []byte(strconv.FormatInt(n, 10)), expireSeconds); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (fc *FreeCache) TTL(key string) (time.Duration, error) {
+	left, err := fc.fc.TTL([]byte(key))
+	if err != nil {
+		if errors.Is(err, freecache.ErrNotFound) {
+			return 0, keyvalue.ErrNotFound
+		}
+		return 0, err
+	}
+	// freecache reports zero for entries without expiration, expired entries are not found
+	if left == 0 {
+		return keyvalue.NoExpiration, nil
+	}
+	return time.Duration(left) * time.Second, nil
+}
+
+func (fc *FreeCache) GetDel(key string) ([]byte, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	res, err := fc.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !fc.fc.Del([]byte(key)) {
+		return nil, keyvalue.ErrNotFound
+	}
+	return res, nil
+}
+
+// seconds converts an expiration to the seconds freecache takes, expirations under a second
+// are rounded up as freecache keeps entries of zero seconds forever
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return max(int(d.Seconds()), 1)
+}
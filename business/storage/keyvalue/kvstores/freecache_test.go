@@ -0,0 +1,113 @@
+package kvstores_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
+)
+
+func TestFreeCache(t *testing.T) {
+	fc := kvstores.NewFreeCache(1024 * 100)
+
+	ok, err := fc.SetNX("nx", []byte("first"), time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("should set absent key, ok: %t, err: %v", ok, err)
+	}
+	ok, err = fc.SetNX("nx", []byte("second"), time.Minute)
+	if err != nil || ok {
+		t.Fatalf("should not set present key, ok: %t, err: %v", ok, err)
+	}
+	if v, _ := fc.Get("nx"); string(v) != "first" {
+		t.Errorf("value should be kept, got %q", v)
+	}
+
+	ttl, err := fc.TTL("nx")
+	if err != nil {
+		t.Fatalf("should get ttl: %s", err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl should be within a minute, got %s", ttl)
+	}
+
+	for name, set := range map[string]func(key string) error{
+		"set":   func(key string) error { return fc.Set(key, []byte("v"), time.Millisecond) },
+		"setnx": func(key string) error { _, err := fc.SetNX(key, []byte("v"), time.Millisecond); return err },
+		"incr":  func(key string) error { _, err := fc.Incr(key, time.Millisecond); return err },
+	} {
+		if err := set("short-" + name); err != nil {
+			t.Fatalf("%s should set key expiring in under a second: %s", name, err)
+		}
+		if ttl, err := fc.TTL("short-" + name); err != nil || ttl == keyvalue.NoExpiration {
+			t.Errorf("%s should keep the expiration of keys expiring in under a second, got %s, err: %v", name, ttl, err)
+		}
+	}
+
+	if err := fc.Set("persistent", []byte("v"), 0); err != nil {
+		t.Fatalf("should set key: %s", err)
+	}
+	if ttl, _ := fc.TTL("persistent"); ttl != keyvalue.NoExpiration {
+		t.Errorf("ttl should be NoExpiration, got %s", ttl)
+	}
+	if _, err := fc.TTL("missing"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("ttl of missing key should yield not found, got %v", err)
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := fc.Incr("counter", time.Minute)
+		if err != nil {
+			t.Fatalf("should incr: %s", err)
+		}
+		if n != want {
+			t.Errorf("counter want %d got %d", want, n)
+		}
+	}
+	if ttl, _ := fc.TTL("counter"); ttl <= 0 {
+		t.Errorf("counter should keep its expiration, got %s", ttl)
+	}
+	if _, err := fc.Incr("nx", time.Minute); !errors.Is(err, keyvalue.ErrNotInteger) {
+		t.Errorf("should not incr non-integer values, got %v", err)
+	}
+
+	// Only one of the concurrent callers should get the value
+	if err := fc.Set("once", []byte("v"), time.Minute); err != nil {
+		t.Fatalf("should set key: %s", err)
+	}
+	var got atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := fc.GetDel("once"); err == nil {
+				got.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got.Load() != 1 {
+		t.Errorf("exactly one getdel should succeed, succeeded: %d", got.Load())
+	}
+	if _, err := fc.Get("once"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("key should be deleted, got %v", err)
+	}
+}
+
+func TestFreeCacheMaxEntrySize(t *testing.T) {
+	for _, size := range []int{0, 1 << 20, 64 << 20} {
+		fc := kvstores.NewFreeCache(size)
+		limit := fc.MaxEntrySize()
+
+		const key = "key"
+		if err := fc.Set(key, make([]byte, limit-len(key)), time.Minute); err != nil {
+			t.Errorf("size %d: should set entry of %d bytes: %s", size, limit, err)
+		}
+		if err := fc.Set(key, make([]byte, limit-len(key)+1), time.Minute); err == nil {
+			t.Errorf("size %d: should not set entry larger than %d bytes", size, limit)
+		}
+	}
+}
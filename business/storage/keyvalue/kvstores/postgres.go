@@ -0,0 +1,325 @@
+package kvstores
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/so-heil/wishlist/business/database/db"
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"go.uber.org/zap"
+)
+
+// PostgresConfig configures the postgres keyvalue store and its sweeper
+type PostgresConfig struct {
+	// OpTimeout bounds every single operation on the store
+	OpTimeout time.Duration
+	// SweepInterval is the period between sweeps of expired rows, zero disables the sweeper
+	SweepInterval time.Duration
+	// SweepBatch is the maximum number of rows deleted by a single statement
+	SweepBatch int
+}
+
+// Postgres is a keyvalue store persisted on the kv table, expired keys are removed lazily
+// on Get and periodically by a background sweeper
+type Postgres struct {
+	dbase *db.DB
+	cfg   PostgresConfig
+	l     *zap.SugaredLogger
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+const invalidTextRepresentation = "22P02"
+
+type dbKV struct {
+	Key       string       `db:"key"`
+	Value     []byte       `db:"value"`
+	ExpiresAt sql.NullTime `db:"expires_at"`
+	Now       time.Time    `db:"now"`
+}
+
+func NewPostgres(dbase *db.DB, cfg PostgresConfig, l *zap.SugaredLogger) *Postgres {
+	if cfg.OpTimeout <= 0 {
+		cfg.OpTimeout = 3 * time.Second
+	}
+	if cfg.SweepBatch <= 0 {
+		cfg.SweepBatch = 1000
+	}
+
+	p := &Postgres{
+		dbase: dbase,
+		cfg:   cfg,
+		l:     l,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	if cfg.SweepInterval > 0 {
+		go p.sweeper()
+	} else {
+		close(p.done)
+	}
+
+	return p
+}
+
+func (p *Postgres) Set(key string, data []byte, expire time.Duration) error {
+	const q = `
+	INSERT INTO "kv"
+			(key, value, expires_at)
+		VALUES
+			(:key, :value, :expires_at)
+		ON CONFLICT (key) DO UPDATE
+			SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+	defer cancel()
+
+	if err := p.dbase.NamedExecContext(ctx, q, toDBKV(key, data, expire)); err != nil {
+		return fmt.Errorf("postgres kv set: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) Get(key string) ([]byte, error) {
+	const q = `SELECT key, value, expires_at FROM "kv" WHERE key = :key`
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+	defer cancel()
+
+	kv := dbKV{Key: key}
+	if err := p.dbase.NamedQueryStructUpdate(ctx, q, &kv); err != nil {
+		if errors.Is(err, db.ErrDBNotFound) {
+			return nil, keyvalue.ErrNotFound
+		}
+		return nil, fmt.Errorf("postgres kv get: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if kv.expired(now) {
+		// Only delete when it is still the expired row, a concurrent Set may have replaced it
+		const del = `DELETE FROM "kv" WHERE key = :key AND expires_at <= :now`
+		kv.Now = now
+		if err := p.dbase.NamedExecContext(ctx, del, kv); err != nil {
+			p.l.Errorw("postgres kv: lazy expiry failed", "key", key, "ERROR", err)
+		}
+		return nil, keyvalue.ErrNotFound
+	}
+
+	return kv.Value, nil
+}
+
+func (p *Postgres) Del(key string) bool {
+	const q = `DELETE FROM "kv" WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+	defer cancel()
+
+	res, err := p.dbase.ExecContext(ctx, q, key)
+	if err != nil {
+		return false
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0
+}
+
+func (p *Postgres) SetNX(key string, data []byte, expire time.Duration) (bool, error) {
+	// An expired row that is not swept yet counts as absent
+	const q = `
+	INSERT INTO "kv"
+			(key, value, expires_at)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+			SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at
+			WHERE "kv".expires_at <= $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+	defer cancel()
+
+	kv := toDBKV(key, data, expire)
+	res, err := p.dbase.ExecContext(ctx, q, kv.Key, kv.Value, kv.ExpiresAt, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("postgres kv setnx: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("postgres kv setnx: rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (p *Postgres) Incr(key string, expire time.Duration) (int64, error) {
+	const q = `
+	INSERT INTO "kv"
+			(key, value, expires_at)
+		VALUES
+			($1, convert_to('1', 'UTF8'), $2)
+		ON CONFLICT (key) DO UPDATE
+			SET value = CASE
+					WHEN "kv".expires_at <= $3 THEN EXCLUDED.value
+					ELSE convert_to((convert_from("kv".value, 'UTF8')::BIGINT + 1)::TEXT, 'UTF8')
+				END,
+				expires_at = CASE
+					WHEN "kv".expires_at <= $3 THEN EXCLUDED.expires_at
+					ELSE "kv".expires_at
+				END
+		RETURNING convert_from(value, 'UTF8')::BIGINT`
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+	defer cancel()
+
+	kv := toDBKV(key, nil, expire)
+	var n int64
+	if err := p.dbase.QueryRowContext(ctx, q, kv.Key, kv.ExpiresAt, time.Now().UTC()).Scan(&n); err != nil {
+		var pqerr *pq.Error
+		if errors.As(err, &pqerr) && pqerr.Code == invalidTextRepresentation {
+			return 0, keyvalue.ErrNotInteger
+		}
+		return 0, fmt.Errorf("postgres kv incr: %w", err)
+	}
+	return n, nil
+}
+
+func (p *Postgres) TTL(key string) (time.Duration, error) {
+	const q = `SELECT key, expires_at FROM "kv" WHERE key = :key`
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+	defer cancel()
+
+	kv := dbKV{Key: key}
+	if err := p.dbase.NamedQueryStructUpdate(ctx, q, &kv); err != nil {
+		if errors.Is(err, db.ErrDBNotFound) {
+			return 0, keyvalue.ErrNotFound
+		}
+		return 0, fmt.Errorf("postgres kv ttl: %w", err)
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case !kv.ExpiresAt.Valid:
+		return keyvalue.NoExpiration, nil
+	case kv.expired(now):
+		return 0, keyvalue.ErrNotFound
+	default:
+		return kv.ExpiresAt.Time.Sub(now), nil
+	}
+}
+
+func (p *Postgres) GetDel(key string) ([]byte, error) {
+	const q = `DELETE FROM "kv" WHERE key = $1 RETURNING value, expires_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+	defer cancel()
+
+	var kv dbKV
+	if err := p.dbase.QueryRowContext(ctx, q, key).Scan(&kv.Value, &kv.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, keyvalue.ErrNotFound
+		}
+		return nil, fmt.Errorf("postgres kv getdel: %w", err)
+	}
+
+	if kv.expired(time.Now().UTC()) {
+		return nil, keyvalue.ErrNotFound
+	}
+	return kv.Value, nil
+}
+
+// StatusCheck makes sure the kv table is reachable
+func (p *Postgres) StatusCheck(ctx context.Context) error {
+	const q = `SELECT COUNT(*) FROM (SELECT 1 FROM "kv" LIMIT 1) AS t`
+
+	var n int
+	if err := p.dbase.QueryRowContext(ctx, q).Scan(&n); err != nil {
+		return fmt.Errorf("query kv table: %w", err)
+	}
+	return nil
+}
+
+// Close stops the sweeper and waits for it to return, the underlying database is not closed
+func (p *Postgres) Close() error {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	<-p.done
+	return nil
+}
+
+func (p *Postgres) sweeper() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			n, err := p.sweep()
+			if err != nil {
+				p.l.Errorw("postgres kv sweeper: failed", "swept", n, "ERROR", err)
+				continue
+			}
+			if n > 0 {
+				p.l.Infow("postgres kv sweeper: swept expired keys", "swept", n)
+			}
+		}
+	}
+}
+
+// sweep deletes expired rows in batches until a batch comes back short
+func (p *Postgres) sweep() (int64, error) {
+	const q = `
+	DELETE FROM "kv"
+		WHERE key IN (
+			SELECT key FROM "kv"
+				WHERE expires_at <= $1
+				LIMIT $2
+		)`
+
+	var total int64
+	for {
+		select {
+		case <-p.stop:
+			return total, nil
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.cfg.OpTimeout)
+		res, err := p.dbase.ExecContext(ctx, q, time.Now().UTC(), p.cfg.SweepBatch)
+		cancel()
+		if err != nil {
+			return total, fmt.Errorf("delete expired batch: %w", err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("rows affected: %w", err)
+		}
+		total += n
+
+		if n < int64(p.cfg.SweepBatch) {
+			return total, nil
+		}
+	}
+}
+
+func toDBKV(key string, data []byte, expire time.Duration) dbKV {
+	kv := dbKV{Key: key, Value: data}
+	if expire > 0 {
+		kv.ExpiresAt = sql.NullTime{Time: time.Now().UTC().Add(expire), Valid: true}
+	}
+	return kv
+}
+
+func (kv dbKV) expired(now time.Time) bool {
+	return kv.ExpiresAt.Valid && !kv.ExpiresAt.Time.After(now)
+}
@@ -0,0 +1,127 @@
+package kvstores_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
+	"github.com/so-heil/wishlist/foundation/apitest"
+)
+
+func TestPostgres(t *testing.T) {
+	l, err := apitest.Logger(true)
+	if err != nil {
+		t.Fatalf("create logger: %s", err)
+	}
+
+	database, err := apitest.NewDatabase(apitest.DatabaseConfig{
+		ShouldMigrate:  true,
+		ConnectTimeout: 10 * time.Second,
+	}, l)
+	if err != nil {
+		t.Fatalf("create database: %s", err)
+	}
+	defer database.Close()
+
+	p := kvstores.NewPostgres(database.Dbase, kvstores.PostgresConfig{
+		SweepInterval: 100 * time.Millisecond,
+		SweepBatch:    2,
+	}, l)
+	defer p.Close()
+
+	if err := p.StatusCheck(context.Background()); err != nil {
+		t.Errorf("status check should pass: %s", err)
+	}
+
+	if _, err := p.Get("missing"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("should yield not found for missing key, got: %v", err)
+	}
+
+	if err := p.Set("key", []byte("value"), time.Second); err != nil {
+		t.Fatalf("should set key: %s", err)
+	}
+	if err := p.Set("key", []byte("updated"), time.Second); err != nil {
+		t.Fatalf("should overwrite key: %s", err)
+	}
+
+	got, err := p.Get("key")
+	if err != nil {
+		t.Fatalf("should get key: %s", err)
+	}
+	if string(got) != "updated" {
+		t.Errorf("value want %q got %q", "updated", got)
+	}
+
+	if !p.Del("key") {
+		t.Error("should delete existing key")
+	}
+	if p.Del("key") {
+		t.Error("should not report deleting a missing key")
+	}
+
+	ok, err := p.SetNX("nx", []byte("first"), time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("should set absent key, ok: %t, err: %v", ok, err)
+	}
+	ok, err = p.SetNX("nx", []byte("second"), time.Minute)
+	if err != nil || ok {
+		t.Fatalf("should not set present key, ok: %t, err: %v", ok, err)
+	}
+	if ttl, err := p.TTL("nx"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl should be within a minute, got %s, err: %v", ttl, err)
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := p.Incr("counter", time.Minute)
+		if err != nil {
+			t.Fatalf("should incr: %s", err)
+		}
+		if n != want {
+			t.Errorf("counter want %d got %d", want, n)
+		}
+	}
+	if _, err := p.Incr("nx", time.Minute); !errors.Is(err, keyvalue.ErrNotInteger) {
+		t.Errorf("should not incr non-integer values, got %v", err)
+	}
+
+	v, err := p.GetDel("nx")
+	if err != nil || string(v) != "first" {
+		t.Errorf("should get and delete key, got %q, err: %v", v, err)
+	}
+	if _, err := p.GetDel("nx"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("key should be deleted, got %v", err)
+	}
+	p.Del("counter")
+
+	// Lazy expiry, the sweeper might be faster but the result is the same for Get
+	if err := p.Set("short", []byte("v"), 10*time.Millisecond); err != nil {
+		t.Fatalf("should set key: %s", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := p.Get("short"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("key should be expired, got: %v", err)
+	}
+
+	// Sweeper should remove expired rows over multiple batches
+	for i := 0; i < 5; i++ {
+		if err := p.Set(fmt.Sprintf("sweep-%d", i), []byte("v"), time.Millisecond); err != nil {
+			t.Fatalf("should set key: %s", err)
+		}
+	}
+	if err := p.Set("keep", []byte("v"), 0); err != nil {
+		t.Fatalf("should set key without expiration: %s", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	var rows int
+	if err := database.Dbase.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM "kv"`).Scan(&rows); err != nil {
+		t.Fatalf("count kv rows: %s", err)
+	}
+	if rows != 1 {
+		t.Errorf("sweeper should leave only the persistent key, rows: %d", rows)
+	}
+}
@@ -0,0 +1,431 @@
+package kvstores
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+)
+
+// RedisConfig describes how to reach a server speaking the redis protocol (RESP)
+type RedisConfig struct {
+	Address     string
+	Password    string
+	DB          int
+	PoolSize    int
+	DialTimeout time.Duration
+	IOTimeout   time.Duration
+}
+
+// Redis is a keyvalue store backed by a redis compatible server, unlike FreeCache
+// its data is shared between every instance of the application
+type Redis struct {
+	cfg  RedisConfig
+	pool chan *respConn
+}
+
+// RedisError is an error reply sent back by the server
+type RedisError string
+
+func (re RedisError) Error() string {
+	return string(re)
+}
+
+var errNilReply = errors.New("nil reply")
+
+func NewRedis(cfg RedisConfig) (*Redis, error) {
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 10
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	if cfg.IOTimeout <= 0 {
+		cfg.IOTimeout = 3 * time.Second
+	}
+
+	r := &Redis{
+		cfg:  cfg,
+		pool: make(chan *respConn, cfg.PoolSize),
+	}
+
+	// Dial once to make sure server is reachable and credentials are valid
+	conn, err := r.dial()
+	if err != nil {
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+	r.put(conn)
+
+	return r, nil
+}
+
+func (r *Redis) Set(key string, data []byte, expire time.Duration) error {
+	args := []string{"SET", key, string(data)}
+	if expire > 0 {
+		args = append(args, "PX", milliseconds(expire))
+	}
+
+	if _, err := r.do(args...); err != nil {
+		return fmt.Errorf("redis set: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) Get(key string) ([]byte, error) {
+	res, err := r.do("GET", key)
+	if err != nil {
+		if errors.Is(err, errNilReply) {
+			return nil, keyvalue.ErrNotFound
+		}
+		return nil, fmt.Errorf("redis get: %w", err)
+	}
+
+	data, ok := res.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis get: unexpected reply %v", res)
+	}
+	return data, nil
+}
+
+func (r *Redis) Del(key string) bool {
+	res, err := r.do("DEL", key)
+	if err != nil {
+		return false
+	}
+	n, ok := res.(int64)
+	return ok && n > 0
+}
+
+func (r *Redis) SetNX(key string, data []byte, expire time.Duration) (bool, error) {
+	args := []string{"SET", key, string(data), "NX"}
+	if expire > 0 {
+		args = append(args, "PX", milliseconds(expire))
+	}
+
+	if _, err := r.do(args...); err != nil {
+		if errors.Is(err, errNilReply) {
+			return false, nil
+		}
+		return false, fmt.Errorf("redis set nx: %w", err)
+	}
+	return true, nil
+}
+
+// Incr needs a server supporting the NX option of PEXPIRE (redis 7.0 and later),
+// which only sets an expiration on keys that have none, so it is a no-op for present keys
+func (r *Redis) Incr(key string, expire time.Duration) (int64, error) {
+	cmds := [][]string{{"INCR", key}}
+	if expire > 0 {
+		cmds = append(cmds, []string{"PEXPIRE", key, milliseconds(expire), "NX"})
+	}
+
+	res, err := r.pipeline(cmds...)
+	if err != nil {
+		var rerr RedisError
+		if errors.As(err, &rerr) && strings.Contains(string(rerr), "not an integer") {
+			return 0, keyvalue.ErrNotInteger
+		}
+		return 0, fmt.Errorf("redis incr: %w", err)
+	}
+
+	n, ok := res[0].(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis incr: unexpected reply %v", res[0])
+	}
+	return n, nil
+}
+
+func (r *Redis) TTL(key string) (time.Duration, error) {
+	res, err := r.do("PTTL", key)
+	if err != nil {
+		return 0, fmt.Errorf("redis pttl: %w", err)
+	}
+
+	ms, ok := res.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis pttl: unexpected reply %v", res)
+	}
+
+	switch ms {
+	case -2:
+		return 0, keyvalue.ErrNotFound
+	case -1:
+		return keyvalue.NoExpiration, nil
+	default:
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+}
+
+// GetDel needs a server supporting GETDEL (redis 6.2 and later)
+func (r *Redis) GetDel(key string) ([]byte, error) {
+	res, err := r.do("GETDEL", key)
+	if err != nil {
+		if errors.Is(err, errNilReply) {
+			return nil, keyvalue.ErrNotFound
+		}
+		return nil, fmt.Errorf("redis getdel: %w", err)
+	}
+
+	data, ok := res.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis getdel: unexpected reply %v", res)
+	}
+	return data, nil
+}
+
+// StatusCheck pings the server
+func (r *Redis) StatusCheck(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	res, err := r.do("PING")
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	if res != "PONG" {
+		return fmt.Errorf("ping: unexpected reply %v", res)
+	}
+	return nil
+}
+
+// Close closes all idle connections of the pool
+func (r *Redis) Close() error {
+	for {
+		select {
+		case conn := <-r.pool:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// milliseconds formats a positive expiration for PX and PEXPIRE, expirations under
+// a millisecond are rounded up as the server rejects zero
+func milliseconds(d time.Duration) string {
+	return strconv.FormatInt(max(d.Milliseconds(), 1), 10)
+}
+
+func (r *Redis) do(args ...string) (any, error) {
+	conn, err := r.get()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := conn.do(r.cfg.IOTimeout, args...)
+	if err != nil {
+		var rerr RedisError
+		// Server errors and nil replies leave the connection in a clean state
+		if errors.As(err, &rerr) || errors.Is(err, errNilReply) {
+			r.put(conn)
+		} else {
+			conn.Close()
+		}
+		return nil, err
+	}
+
+	r.put(conn)
+	return res, nil
+}
+
+// pipeline sends all commands on one connection before reading their replies,
+// the first error reply is returned after every reply has been read
+func (r *Redis) pipeline(cmds ...[]string) ([]any, error) {
+	conn, err := r.get()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := conn.pipeline(r.cfg.IOTimeout, cmds)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r.put(conn)
+
+	for i := range res {
+		if err, ok := res[i].(error); ok {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (r *Redis) get() (*respConn, error) {
+	select {
+	case conn := <-r.pool:
+		return conn, nil
+	default:
+		return r.dial()
+	}
+}
+
+func (r *Redis) put(conn *respConn) {
+	select {
+	case r.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (r *Redis) dial() (*respConn, error) {
+	nc, err := net.DialTimeout("tcp", r.cfg.Address, r.cfg.DialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	conn := &respConn{
+		Conn: nc,
+		rd:   bufio.NewReader(nc),
+		wr:   bufio.NewWriter(nc),
+	}
+
+	if r.cfg.Password != "" {
+		if _, err := conn.do(r.cfg.IOTimeout, "AUTH", r.cfg.Password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if r.cfg.DB != 0 {
+		if _, err := conn.do(r.cfg.IOTimeout, "SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("select db: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// respConn is a single connection to the server which writes commands and reads replies in RESP
+type respConn struct {
+	net.Conn
+	rd *bufio.Reader
+	wr *bufio.Writer
+}
+
+func (c *respConn) do(timeout time.Duration, args ...string) (any, error) {
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("set deadline: %w", err)
+	}
+
+	if err := c.writeCommand(args); err != nil {
+		return nil, fmt.Errorf("write command: %w", err)
+	}
+
+	return c.readReply()
+}
+
+// pipeline reads a reply for each command, error replies are kept in place of the reply
+func (c *respConn) pipeline(timeout time.Duration, cmds [][]string) ([]any, error) {
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("set deadline: %w", err)
+	}
+
+	for _, args := range cmds {
+		c.writeArgs(args)
+	}
+	if err := c.wr.Flush(); err != nil {
+		return nil, fmt.Errorf("write commands: %w", err)
+	}
+
+	res := make([]any, len(cmds))
+	for i := range res {
+		reply, err := c.readReply()
+		var rerr RedisError
+		switch {
+		case errors.As(err, &rerr):
+			res[i] = rerr
+		case errors.Is(err, errNilReply):
+		case err != nil:
+			return nil, err
+		default:
+			res[i] = reply
+		}
+	}
+	return res, nil
+}
+
+func (c *respConn) writeCommand(args []string) error {
+	c.writeArgs(args)
+	return c.wr.Flush()
+}
+
+func (c *respConn) writeArgs(args []string) {
+	fmt.Fprintf(c.wr, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.wr, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+func (c *respConn) readReply() (any, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse integer reply: %w", err)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("parse bulk length: %w", err)
+		}
+		if n < 0 {
+			return nil, errNilReply
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, buf); err != nil {
+			return nil, fmt.Errorf("read bulk: %w", err)
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("parse array length: %w", err)
+		}
+		if n < 0 {
+			return nil, errNilReply
+		}
+		res := make([]any, n)
+		for i := range res {
+			res[i], err = c.readReply()
+			if err != nil && !errors.Is(err, errNilReply) {
+				return nil, err
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unknown reply type %q", line[0])
+	}
+}
+
+func (c *respConn) readLine() (string, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("read reply: %w", err)
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed reply line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
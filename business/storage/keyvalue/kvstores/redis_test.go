@@ -0,0 +1,362 @@
+package kvstores_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
+)
+
+func TestRedis(t *testing.T) {
+	srv := newRESPServer(t, "secret")
+	defer srv.Close()
+
+	if _, err := kvstores.NewRedis(kvstores.RedisConfig{Address: srv.addr, Password: "wrong"}); err == nil {
+		t.Fatal("should not connect with wrong password")
+	}
+
+	r, err := kvstores.NewRedis(kvstores.RedisConfig{Address: srv.addr, Password: "secret", DB: 1})
+	if err != nil {
+		t.Fatalf("should connect to resp server: %s", err)
+	}
+	defer r.Close()
+
+	if err := r.StatusCheck(context.Background()); err != nil {
+		t.Errorf("status check should pass: %s", err)
+	}
+
+	if _, err := r.Get("missing"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("should yield not found for missing key, got: %v", err)
+	}
+
+	if err := r.Set("key", []byte("value\r\nwith crlf"), 200*time.Millisecond); err != nil {
+		t.Fatalf("should set key: %s", err)
+	}
+
+	got, err := r.Get("key")
+	if err != nil {
+		t.Fatalf("should get key: %s", err)
+	}
+	if string(got) != "value\r\nwith crlf" {
+		t.Errorf("value want %q got %q", "value\r\nwith crlf", got)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if _, err := r.Get("key"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("key should be expired, got: %v", err)
+	}
+
+	if err := r.Set("short", []byte("v"), time.Microsecond); err != nil {
+		t.Errorf("should set key expiring in under a millisecond: %s", err)
+	}
+
+	if err := r.Set("persistent", []byte("v"), 0); err != nil {
+		t.Fatalf("should set key without expiration: %s", err)
+	}
+	if !r.Del("persistent") {
+		t.Error("should delete existing key")
+	}
+	if r.Del("persistent") {
+		t.Error("should not report deleting a missing key")
+	}
+
+	ok, err := r.SetNX("nx", []byte("first"), time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("should set absent key, ok: %t, err: %v", ok, err)
+	}
+	ok, err = r.SetNX("nx", []byte("second"), time.Minute)
+	if err != nil || ok {
+		t.Fatalf("should not set present key, ok: %t, err: %v", ok, err)
+	}
+
+	if ttl, err := r.TTL("nx"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl should be within a minute, got %s, err: %v", ttl, err)
+	}
+	if _, err := r.TTL("missing"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("ttl of missing key should yield not found, got %v", err)
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := r.Incr("counter", time.Minute)
+		if err != nil {
+			t.Fatalf("should incr: %s", err)
+		}
+		if n != want {
+			t.Errorf("counter want %d got %d", want, n)
+		}
+	}
+	if ttl, _ := r.TTL("counter"); ttl <= 0 {
+		t.Errorf("counter should have an expiration, got %s", ttl)
+	}
+	if _, err := r.Incr("nx", time.Minute); !errors.Is(err, keyvalue.ErrNotInteger) {
+		t.Errorf("should not incr non-integer values, got %v", err)
+	}
+
+	v, err := r.GetDel("nx")
+	if err != nil || string(v) != "first" {
+		t.Errorf("should get and delete key, got %q, err: %v", v, err)
+	}
+	if _, err := r.GetDel("nx"); !errors.Is(err, keyvalue.ErrNotFound) {
+		t.Errorf("key should be deleted, got %v", err)
+	}
+
+	// Concurrent usage should share the pool safely
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("concurrent-%d", i)
+			if err := r.Set(key, []byte(key), time.Second); err != nil {
+				t.Errorf("should set %s: %s", key, err)
+				return
+			}
+			v, err := r.Get(key)
+			if err != nil || string(v) != key {
+				t.Errorf("should get %s back, got %q: %v", key, v, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// respServer is an in-process stand-in for a redis server, it understands
+// just enough of the protocol for the commands used by kvstores.Redis
+type respServer struct {
+	ln       net.Listener
+	addr     string
+	password string
+
+	mu   sync.Mutex
+	data map[string]respEntry
+}
+
+type respEntry struct {
+	value  string
+	expire time.Time
+}
+
+func newRESPServer(t *testing.T, password string) *respServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+
+	srv := &respServer{
+		ln:       ln,
+		addr:     ln.Addr().String(),
+		password: password,
+		data:     make(map[string]respEntry),
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+
+	return srv
+}
+
+func (srv *respServer) Close() error {
+	return srv.ln.Close()
+}
+
+func (srv *respServer) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := srv.password == ""
+
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(args[0])
+		var reply string
+		switch {
+		case cmd == "AUTH":
+			if len(args) == 2 && args[1] == srv.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = srv.exec(cmd, args[1:])
+		}
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (srv *respServer) exec(cmd string, args []string) string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		if len(args) < 2 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		e := respEntry{value: args[1]}
+		var nx bool
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				if i+1 >= len(args) {
+					return "-ERR syntax error\r\n"
+				}
+				ms, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return "-ERR value is not an integer or out of range\r\n"
+				}
+				if ms <= 0 {
+					return "-ERR invalid expire time in 'set' command\r\n"
+				}
+				e.expire = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				i++
+			}
+		}
+		if _, ok := srv.lookup(args[0]); ok && nx {
+			return "$-1\r\n"
+		}
+		srv.data[args[0]] = e
+		return "+OK\r\n"
+	case "GETDEL":
+		e, ok := srv.lookup(args[0])
+		if !ok {
+			return "$-1\r\n"
+		}
+		delete(srv.data, args[0])
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(e.value), e.value)
+	case "INCR":
+		e, _ := srv.lookup(args[0])
+		n := 0
+		if e.value != "" {
+			var err error
+			if n, err = strconv.Atoi(e.value); err != nil {
+				return "-ERR value is not an integer or out of range\r\n"
+			}
+		}
+		e.value = strconv.Itoa(n + 1)
+		srv.data[args[0]] = e
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "PEXPIRE":
+		e, ok := srv.lookup(args[0])
+		if !ok {
+			return ":0\r\n"
+		}
+		if len(args) == 3 && strings.ToUpper(args[2]) == "NX" && !e.expire.IsZero() {
+			return ":0\r\n"
+		}
+		ms, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		e.expire = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		srv.data[args[0]] = e
+		return ":1\r\n"
+	case "PTTL":
+		e, ok := srv.lookup(args[0])
+		switch {
+		case !ok:
+			return ":-2\r\n"
+		case e.expire.IsZero():
+			return ":-1\r\n"
+		default:
+			return fmt.Sprintf(":%d\r\n", time.Until(e.expire).Milliseconds())
+		}
+	case "GET":
+		e, ok := srv.lookup(args[0])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(e.value), e.value)
+	case "DEL":
+		var n int
+		for _, k := range args {
+			if _, ok := srv.lookup(k); ok {
+				delete(srv.data, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd)
+	}
+}
+
+// lookup must be called with mu held
+func (srv *respServer) lookup(key string) (respEntry, bool) {
+	e, ok := srv.data[key]
+	if !ok {
+		return respEntry{}, false
+	}
+	if !e.expire.IsZero() && e.expire.Before(time.Now()) {
+		delete(srv.data, key)
+		return respEntry{}, false
+	}
+	return e, true
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return args, nil
+}
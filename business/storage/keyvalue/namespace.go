@@ -0,0 +1,53 @@
+package keyvalue
+
+import "time"
+
+// NamespaceSeparator separates the namespace from the key, nested namespaces are joined by it as well
+const NamespaceSeparator = ":"
+
+// Namespaced is a KeyValueStore that prefixes every key with its namespace so features
+// sharing the same underlying store do not collide on their keys
+type Namespaced struct {
+	s      KeyValueStore
+	prefix string
+}
+
+// Namespace wraps the store, keys are stored as "<namespace>:<key>"
+func Namespace(s KeyValueStore, namespace string) *Namespaced {
+	return &Namespaced{
+		s:      s,
+		prefix: namespace + NamespaceSeparator,
+	}
+}
+
+func (ns *Namespaced) key(key string) string {
+	return ns.prefix + key
+}
+
+func (ns *Namespaced) Set(key string, data []byte, expire time.Duration) error {
+	return ns.s.Set(ns.key(key), data, expire)
+}
+
+func (ns *Namespaced) Get(key string) ([]byte, error) {
+	return ns.s.Get(ns.key(key))
+}
+
+func (ns *Namespaced) Del(key string) bool {
+	return ns.s.Del(ns.key(key))
+}
+
+func (ns *Namespaced) SetNX(key string, data []byte, expire time.Duration) (bool, error) {
+	return ns.s.SetNX(ns.key(key), data, expire)
+}
+
+func (ns *Namespaced) Incr(key string, expire time.Duration) (int64, error) {
+	return ns.s.Incr(ns.key(key), expire)
+}
+
+func (ns *Namespaced) TTL(key string) (time.Duration, error) {
+	return ns.s.TTL(ns.key(key))
+}
+
+func (ns *Namespaced) GetDel(key string) ([]byte, error) {
+	return ns.s.GetDel(ns.key(key))
+}
@@ -0,0 +1,105 @@
+package keyvalue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Codec encodes values to bytes to be stored and decodes them back
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes values with encoding/json
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes values with encoding/gob, a compact format when only Go reads the values back
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Typed stores values of type T in a KeyValueStore, (de)serializing them with its codec
+type Typed[T any] struct {
+	s     KeyValueStore
+	codec Codec
+}
+
+// NewTyped wraps the store, a nil codec defaults to JSONCodec
+func NewTyped[T any](s KeyValueStore, codec Codec) *Typed[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &Typed[T]{s: s, codec: codec}
+}
+
+func (t *Typed[T]) Set(key string, val T, expire time.Duration) error {
+	data, err := t.codec.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("marshal value: %w", err)
+	}
+	return t.s.Set(key, data, expire)
+}
+
+func (t *Typed[T]) Get(key string) (T, error) {
+	data, err := t.s.Get(key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return t.decode(data)
+}
+
+func (t *Typed[T]) Del(key string) bool {
+	return t.s.Del(key)
+}
+
+func (t *Typed[T]) SetNX(key string, val T, expire time.Duration) (bool, error) {
+	data, err := t.codec.Marshal(val)
+	if err != nil {
+		return false, fmt.Errorf("marshal value: %w", err)
+	}
+	return t.s.SetNX(key, data, expire)
+}
+
+func (t *Typed[T]) TTL(key string) (time.Duration, error) {
+	return t.s.TTL(key)
+}
+
+func (t *Typed[T]) GetDel(key string) (T, error) {
+	data, err := t.s.GetDel(key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return t.decode(data)
+}
+
+func (t *Typed[T]) decode(data []byte) (T, error) {
+	var val T
+	if err := t.codec.Unmarshal(data, &val); err != nil {
+		return val, fmt.Errorf("unmarshal value: %w", err)
+	}
+	return val, nil
+}
@@ -0,0 +1,142 @@
+package validate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts every bloom filter file, the byte after it is the format version
+const bloomMagic = "WLBF\x01"
+
+// maxBloomBits bounds the filters read from files to 1GiB of bits
+const maxBloomBits = 8 << 30
+
+// BloomFilter is a set of strings that can tell a string is absent for sure, or present
+// with a false positive rate chosen on creation. It lets the password rule check a list of
+// millions of breached passwords without holding the list in memory.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint8
+}
+
+// NewBloomFilter sizes a filter for n strings with a false positive rate of fpRate
+func NewBloomFilter(n int, fpRate float64) (*BloomFilter, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("bloom filter should hold at least one string, got %d", n)
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("false positive rate should be between 0 and 1, got %f", fpRate)
+	}
+
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+	if m > maxBloomBits {
+		return nil, fmt.Errorf("bloom filter of %.0f bits is too large", m)
+	}
+
+	return newBloomFilter(uint64(m), uint8(math.Min(k, math.MaxUint8))), nil
+}
+
+func newBloomFilter(m uint64, k uint8) *BloomFilter {
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add puts s in the filter
+func (bf *BloomFilter) Add(s string) {
+	h1, h2 := bloomHash(s)
+	for i := uint64(0); i < uint64(bf.k); i++ {
+		idx := (h1 + i*h2) % bf.m
+		bf.bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+// Contains reports whether s may have been added to the filter
+func (bf *BloomFilter) Contains(s string) bool {
+	h1, h2 := bloomHash(s)
+	for i := uint64(0); i < uint64(bf.k); i++ {
+		idx := (h1 + i*h2) % bf.m
+		if bf.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash derives the two hashes of double hashing, h2 is odd so it never degenerates to 0
+func bloomHash(s string) (uint64, uint64) {
+	a := fnv.New64a()
+	a.Write([]byte(s))
+	b := fnv.New64()
+	b.Write([]byte(s))
+	return a.Sum64(), b.Sum64() | 1
+}
+
+// WriteTo writes the filter in the format read by ReadBloomFilter
+func (bf *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+
+	header := make([]byte, 0, len(bloomMagic)+9)
+	header = append(header, bloomMagic...)
+	header = append(header, bf.k)
+	header = binary.BigEndian.AppendUint64(header, bf.m)
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+
+	if err := binary.Write(bw, binary.BigEndian, bf.bits); err != nil {
+		return 0, err
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(header) + 8*len(bf.bits)), nil
+}
+
+// ReadBloomFilter reads a filter written by BloomFilter.WriteTo
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(bloomMagic)+9)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(header[:len(bloomMagic)]) != bloomMagic {
+		return nil, errors.New("not a bloom filter of a supported version")
+	}
+
+	k := header[len(bloomMagic)]
+	m := binary.BigEndian.Uint64(header[len(bloomMagic)+1:])
+	if k == 0 || m == 0 || m > maxBloomBits {
+		return nil, fmt.Errorf("invalid bloom filter with %d hashes and %d bits", k, m)
+	}
+
+	bf := newBloomFilter(m, k)
+	if err := binary.Read(br, binary.BigEndian, bf.bits); err != nil {
+		return nil, fmt.Errorf("read bits: %w", err)
+	}
+
+	return bf, nil
+}
+
+// LoadBloomFilter reads the filter file at path
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadBloomFilter(f)
+}
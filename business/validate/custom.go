@@ -2,55 +2,411 @@ package validate
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	"github.com/so-heil/wishlist/business/i18n"
 )
 
-const minLength = 8
+// Config sets up the custom rules of the validator
+type Config struct {
+	// Password is the policy of the password tag, the zero value means DefaultPasswordPolicy
+	Password PasswordPolicy
+	// ReservedUsernames are rejected by the username tag on top of the built-in blocklist
+	ReservedUsernames []string
+}
 
-func passv(pass string) bool {
-	if len(pass) < minLength {
-		return false
+// messages are the texts of a rule keyed by locale then translation key, {0} is the field
+type messages map[string]map[string]string
+
+// addRule registers the validation of tag and its translations in every supported locale,
+// key picks the translation of a failed field and can be nil when the rule has one text
+func addRule(instance *validator.Validate, tag string, fn validator.Func, msgs messages, key func(validator.FieldError) string) error {
+	if err := instance.RegisterValidation(tag, fn); err != nil {
+		return fmt.Errorf("register %s validator: %w", tag, err)
 	}
+	return addTranslations(instance, tag, msgs, key)
+}
 
-	var (
-		upper int
-		num   int
-	)
+func addTranslations(instance *validator.Validate, tag string, msgs messages, key func(validator.FieldError) string) error {
+	for _, locale := range i18n.Locales() {
+		texts, ok := msgs[locale]
+		if !ok {
+			return fmt.Errorf("no %s validation message for %s", tag, locale)
+		}
+
+		err := instance.RegisterTranslation(tag, i18n.Translator(locale), func(ut ut.Translator) error {
+			for k, text := range texts {
+				if err := ut.Add(k, text, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			k := tag
+			if key != nil {
+				k = key(fe)
+			}
+			t, _ := ut.T(k, fe.Field())
+			return t
+		})
+		if err != nil {
+			return fmt.Errorf("add %s %s validation translation: %w", locale, tag, err)
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// Password
+
+// PasswordPolicy describes the passwords accepted by the password tag
+type PasswordPolicy struct {
+	// MinLength is counted in characters, not bytes
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireNumber bool
+	RequireSymbol bool
+	// BreachedFilter is the path of a bloom filter of breached passwords, see BloomFilter,
+	// passwords the filter may contain are rejected, an empty path disables the check
+	BreachedFilter string
+}
 
+// DefaultPasswordPolicy asks for 8 characters with an upper-case letter and a number
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:     8,
+	RequireUpper:  true,
+	RequireNumber: true,
+}
+
+type passwordRule struct {
+	policy   PasswordPolicy
+	breached *BloomFilter
+}
+
+func newPasswordRule(policy PasswordPolicy) (*passwordRule, error) {
+	if policy == (PasswordPolicy{}) {
+		policy = DefaultPasswordPolicy
+	}
+	if policy.MinLength < 1 {
+		return nil, fmt.Errorf("password min length should be positive, got %d", policy.MinLength)
+	}
+
+	rule := passwordRule{policy: policy}
+	if policy.BreachedFilter != "" {
+		bf, err := LoadBloomFilter(policy.BreachedFilter)
+		if err != nil {
+			return nil, fmt.Errorf("load breached passwords: %w", err)
+		}
+		rule.breached = bf
+	}
+
+	return &rule, nil
+}
+
+// strong reports whether pass has the length and character classes of the policy
+func (pr *passwordRule) strong(pass string) bool {
+	if utf8.RuneCountInString(pass) < pr.policy.MinLength {
+		return false
+	}
+
+	var upper, lower, number, symbol bool
 	for _, char := range pass {
 		switch {
 		case unicode.IsUpper(char):
-			upper++
+			upper = true
+		case unicode.IsLower(char):
+			lower = true
 		case unicode.IsNumber(char):
-			num++
+			number = true
+		case unicode.IsPunct(char), unicode.IsSymbol(char):
+			symbol = true
 		}
 	}
 
-	return upper >= 1 && num >= 1
+	return (upper || !pr.policy.RequireUpper) &&
+		(lower || !pr.policy.RequireLower) &&
+		(number || !pr.policy.RequireNumber) &&
+		(symbol || !pr.policy.RequireSymbol)
+}
+
+func (pr *passwordRule) isBreached(pass string) bool {
+	return pr.breached != nil && pr.breached.Contains(pass)
 }
 
-func addPassword(instance *validator.Validate, trans ut.Translator) error {
-	const tag = "password"
+func (pr *passwordRule) valid(pass string) bool {
+	return pr.strong(pass) && !pr.isBreached(pass)
+}
+
+// passwordText holds the pieces the password messages of a locale are built from
+type passwordText struct {
+	length, classes, breached string
+	upper, lower, number      string
+	symbol, sep, and          string
+}
+
+var passwordTexts = map[string]passwordText{
+	"en": {
+		length:   "{0} should be at least {1} characters long",
+		classes:  "{0} should be at least {1} characters long containing at least {2}",
+		breached: "{0} has appeared in a data breach, choose another one",
+		upper:    "one upper-case letter",
+		lower:    "one lower-case letter",
+		number:   "one number",
+		symbol:   "one symbol",
+		sep:      ", ",
+		and:      " and ",
+	},
+	"fa": {
+		length:   "{0} باید حداقل {1} کاراکتر باشد",
+		classes:  "{0} باید حداقل {1} کاراکتر و شامل حداقل {2} باشد",
+		breached: "{0} در نشت اطلاعات دیده شده است، مقدار دیگری انتخاب کنید",
+		upper:    "یک حرف بزرگ",
+		lower:    "یک حرف کوچک",
+		number:   "یک عدد",
+		symbol:   "یک نماد",
+		sep:      "، ",
+		and:      " و ",
+	},
+	"fr": {
+		length:   "{0} doit contenir au moins {1} caractères",
+		classes:  "{0} doit contenir au moins {1} caractères dont au moins {2}",
+		breached: "{0} est apparu dans une fuite de données, choisissez-en un autre",
+		upper:    "une lettre majuscule",
+		lower:    "une lettre minuscule",
+		number:   "un chiffre",
+		symbol:   "un symbole",
+		sep:      ", ",
+		and:      " et ",
+	},
+}
+
+// messages builds the texts of the password tag for the policy, only the field is left as a parameter
+func (pr *passwordRule) messages() messages {
+	msgs := make(messages, len(passwordTexts))
+	for locale, pt := range passwordTexts {
+		var classes []string
+		for _, c := range []struct {
+			required bool
+			text     string
+		}{
+			{pr.policy.RequireUpper, pt.upper},
+			{pr.policy.RequireLower, pt.lower},
+			{pr.policy.RequireNumber, pt.number},
+			{pr.policy.RequireSymbol, pt.symbol},
+		} {
+			if c.required {
+				classes = append(classes, c.text)
+			}
+		}
+
+		text := pt.length
+		if len(classes) != 0 {
+			text = pt.classes
+		}
+
+		list := classes
+		if n := len(classes); n > 1 {
+			list = []string{strings.Join(classes[:n-1], pt.sep) + pt.and + classes[n-1]}
+		}
+		text = strings.NewReplacer("{1}", strconv.Itoa(pr.policy.MinLength), "{2}", strings.Join(list, "")).Replace(text)
 
-	err := instance.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
-		return ut.Add(tag, "{0} should be at least 8 characters long containing at least one upper-case letter, and one number", true)
-	}, func(ut ut.Translator, fe validator.FieldError) string {
-		t, _ := ut.T(tag, fe.Field())
-		return t
-	})
-	if err != nil {
-		return fmt.Errorf("add password validation translation: %w", err)
+		msgs[locale] = map[string]string{
+			"password":          text,
+			"password_breached": pt.breached,
+		}
 	}
+	return msgs
+}
+
+func addPassword(instance *validator.Validate, rule *passwordRule) error {
+	return addRule(instance, "password",
+		func(fl validator.FieldLevel) bool { return rule.valid(fl.Field().String()) },
+		rule.messages(),
+		func(fe validator.FieldError) string {
+			pass, _ := fe.Value().(string)
+			if rule.strong(pass) {
+				return "password_breached"
+			}
+			return "password"
+		},
+	)
+}
 
-	if err := instance.RegisterValidation(
-		"password",
-		func(fl validator.FieldLevel) bool { return passv(fl.Field().String()) },
-	); err != nil {
-		return fmt.Errorf("register password validator: %w", err)
+// =============================================================================
+// URL
+
+const maxURLLength = 2048
+
+var urlMessages = messages{
+	"en": {"safeurl": "{0} should be a public http or https URL"},
+	"fa": {"safeurl": "{0} باید یک آدرس عمومی http یا https باشد"},
+	"fr": {"safeurl": "{0} doit être une URL http ou https publique"},
+}
+
+// safeURL accepts absolute http(s) URLs of public hosts, links of products are fetched by
+// the server so they must not point into the network it runs in. Hostnames are not resolved,
+// whatever dials these URLs should still refuse private addresses.
+func safeURL(raw string) bool {
+	if raw == "" || len(raw) > maxURLLength {
+		return false
 	}
 
-	return nil
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return false
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return false
+	}
+	if port := u.Port(); port != "" {
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return false
+		}
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+
+	// Single label hosts like localhost or a decimal IP are only reachable on local networks
+	if !strings.Contains(host, ".") {
+		return false
+	}
+	for _, suffix := range []string{".localhost", ".local", ".internal", ".home.arpa"} {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, netip has no predicate for it
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr) &&
+		addr.Zone() == ""
+}
+
+func addSafeURL(instance *validator.Validate) error {
+	return addRule(instance, "safeurl",
+		func(fl validator.FieldLevel) bool { return safeURL(fl.Field().String()) },
+		urlMessages,
+		nil,
+	)
+}
+
+// =============================================================================
+// Currency
+
+var currencyMessages = messages{
+	"en": {"currency": "{0} should be an ISO 4217 currency code such as USD"},
+	"fa": {"currency": "{0} باید یک کد ارز ISO 4217 مانند IRR باشد"},
+	"fr": {"currency": "{0} doit être un code de devise ISO 4217 comme EUR"},
+}
+
+// addCurrency names the iso4217 tag of the validator after what it means to the API
+func addCurrency(instance *validator.Validate) error {
+	instance.RegisterAlias("currency", "iso4217")
+	return addTranslations(instance, "currency", currencyMessages, nil)
+}
+
+// =============================================================================
+// Username
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 20
+)
+
+// reservedUsernames could be mistaken for the service itself or for routes of the web app
+var reservedUsernames = []string{
+	"about", "account", "admin", "administrator", "api", "app", "auth", "billing",
+	"contact", "dashboard", "debug", "help", "home", "info", "login", "logout",
+	"me", "mod", "moderator", "null", "official", "owner", "privacy", "register",
+	"root", "security", "settings", "signin", "signup", "staff", "static", "status",
+	"support", "system", "terms", "undefined", "user", "users", "wishlist", "wishlists", "www",
+}
+
+var usernameMessages = messages{
+	"en": {
+		"username":          "{0} should be 3 to 20 lower-case letters, numbers or underscores starting with a letter",
+		"username_reserved": "{0} is reserved, choose another one",
+	},
+	"fa": {
+		"username":          "{0} باید ۳ تا ۲۰ حرف کوچک، عدد یا زیرخط باشد و با یک حرف شروع شود",
+		"username_reserved": "{0} رزرو شده است، مقدار دیگری انتخاب کنید",
+	},
+	"fr": {
+		"username":          "{0} doit contenir 3 à 20 lettres minuscules, chiffres ou tirets bas et commencer par une lettre",
+		"username_reserved": "{0} est réservé, choisissez-en un autre",
+	},
+}
+
+type usernameRule struct {
+	reserved map[string]struct{}
+}
+
+func newUsernameRule(extra []string) *usernameRule {
+	rule := usernameRule{reserved: make(map[string]struct{}, len(reservedUsernames)+len(extra))}
+	for _, name := range append(reservedUsernames, extra...) {
+		rule.reserved[strings.ToLower(name)] = struct{}{}
+	}
+	return &rule
+}
+
+func (ur *usernameRule) wellFormed(name string) bool {
+	if len(name) < minUsernameLength || len(name) > maxUsernameLength {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '_'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (ur *usernameRule) isReserved(name string) bool {
+	_, ok := ur.reserved[name]
+	return ok
+}
+
+func (ur *usernameRule) valid(name string) bool {
+	return ur.wellFormed(name) && !ur.isReserved(name)
+}
+
+func addUsername(instance *validator.Validate, reserved []string) error {
+	rule := newUsernameRule(reserved)
+
+	return addRule(instance, "username",
+		func(fl validator.FieldLevel) bool { return rule.valid(fl.Field().String()) },
+		usernameMessages,
+		func(fe validator.FieldError) string {
+			name, _ := fe.Value().(string)
+			if rule.wellFormed(name) {
+				return "username_reserved"
+			}
+			return "username"
+		},
+	)
 }
@@ -0,0 +1,44 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/so-heil/wishlist/foundation/openapi"
+)
+
+// RegisterOpenAPITags describes the custom rules in the schemas generated by g, the password
+// policy is the one Init has been called with or the default one before Init
+func RegisterOpenAPITags(g *openapi.Generator) {
+	policy := DefaultPasswordPolicy
+	if v != nil {
+		policy = v.password.policy
+	}
+
+	g.RegisterTag("password", func(s *openapi.Schema, _ string) {
+		minLength := policy.MinLength
+		s.MinLength = &minLength
+		s.Format = "password"
+		s.Description = passwordDescription(policy)
+	})
+	g.RegisterTag("safeurl", func(s *openapi.Schema, _ string) {
+		maxLength := maxURLLength
+		s.Format = "uri"
+		s.Pattern = "^https?://"
+		s.MaxLength = &maxLength
+		s.Description = "public http or https URL, private and local hosts are rejected"
+	})
+	g.RegisterTag("currency", openapi.Pattern("^[A-Z]{3}$"))
+	g.RegisterTag("username", func(s *openapi.Schema, _ string) {
+		s.Pattern = fmt.Sprintf("^[a-z][a-z0-9_]{%d,%d}$", minUsernameLength-1, maxUsernameLength-1)
+		s.Description = "reserved names such as admin are rejected"
+	})
+}
+
+func passwordDescription(policy PasswordPolicy) string {
+	rule := passwordRule{policy: policy}
+	text := rule.messages()["en"]["password"]
+	if policy.BreachedFilter != "" {
+		text += ", breached passwords are rejected"
+	}
+	return text[len("{0} "):]
+}
@@ -3,39 +3,49 @@
 package validate
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
 	"sync"
 
-	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
+	fa_translations "github.com/go-playground/validator/v10/translations/fa"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	"github.com/so-heil/wishlist/business/i18n"
 )
 
+type registerFunc func(*validator.Validate, ut.Translator) error
+
+// defaults are the translations of the built-in tags for each supported locale
+var defaults = map[string]registerFunc{
+	"en": en_translations.RegisterDefaultTranslations,
+	"fa": fa_translations.RegisterDefaultTranslations,
+	"fr": fr_translations.RegisterDefaultTranslations,
+}
+
 type validate struct {
-	v     *validator.Validate
-	trans ut.Translator
+	v        *validator.Validate
+	password *passwordRule
 }
 
 var once sync.Once
 var v *validate
 
-func Init() error {
+// Init sets up the validator with the custom rules described by cfg, only the first call
+// has an effect
+func Init(cfg Config) error {
 	var rterr error
 	once.Do(func() {
-		instance := validator.New()
-
-		enLang := en.New()
-		uni := ut.New(enLang, enLang)
-		trans, found := uni.GetTranslator("en")
-		if !found {
-			rterr = errors.New("cannot find enLang translation")
+		if err := i18n.Init(); err != nil {
+			rterr = fmt.Errorf("init i18n: %w", err)
 			return
 		}
 
+		instance := validator.New()
+
 		instance.RegisterTagNameFunc(func(fld reflect.StructField) string {
 			name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
 			if name == "-" {
@@ -44,36 +54,62 @@ func Init() error {
 			return name
 		})
 
-		if err := en_translations.RegisterDefaultTranslations(instance, trans); err != nil {
-			rterr = err
-			return
+		for _, locale := range i18n.Locales() {
+			register, ok := defaults[locale]
+			if !ok {
+				rterr = fmt.Errorf("no default translations for %s", locale)
+				return
+			}
+			if err := register(instance, i18n.Translator(locale)); err != nil {
+				rterr = fmt.Errorf("register %s translations: %w", locale, err)
+				return
+			}
 		}
 
-		if err := addPassword(instance, trans); err != nil {
+		password, err := newPasswordRule(cfg.Password)
+		if err != nil {
+			rterr = fmt.Errorf("create password rule: %w", err)
+			return
+		}
+		if err := addPassword(instance, password); err != nil {
 			rterr = fmt.Errorf("register password validator: %w", err)
 			return
 		}
+		if err := addSafeURL(instance); err != nil {
+			rterr = err
+			return
+		}
+		if err := addCurrency(instance); err != nil {
+			rterr = err
+			return
+		}
+		if err := addUsername(instance, cfg.ReservedUsernames); err != nil {
+			rterr = err
+			return
+		}
 
 		v = &validate{
-			v:     instance,
-			trans: trans,
+			v:        instance,
+			password: password,
 		}
 	})
 	return rterr
 }
 
-func Check(val any) error {
-	if err := v.v.Struct(val); err != nil {
+// Check validates val, messages of FieldErrors are in the locale of ctx, see i18n.WithLocale
+func Check(ctx context.Context, val any) error {
+	if err := v.v.StructCtx(ctx, val); err != nil {
 		verrors, ok := err.(validator.ValidationErrors)
 		if !ok {
 			return err
 		}
 
+		trans := i18n.Translator(i18n.Locale(ctx))
 		var fields FieldErrors
 		for _, verror := range verrors {
 			field := FieldError{
 				Field: verror.Field(),
-				Err:   verror.Translate(v.trans),
+				Err:   verror.Translate(trans),
 			}
 			fields = append(fields, field)
 		}
@@ -1,11 +1,13 @@
 package validate_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"reflect"
 	"testing"
 
+	"github.com/so-heil/wishlist/business/i18n"
 	"github.com/so-heil/wishlist/business/validate"
 )
 
@@ -17,7 +19,7 @@ type user struct {
 }
 
 func TestCheckStruct(t *testing.T) {
-	err := validate.Init()
+	err := validate.Init(validate.Config{})
 	if err != nil {
 		t.Fatalf("create validator: %s", err)
 	}
@@ -28,7 +30,7 @@ func TestCheckStruct(t *testing.T) {
 		Username: "test",
 		Password: "jiwulR18p",
 	}
-	if err := validate.Check(validUser); err != nil {
+	if err := validate.Check(context.Background(), validUser); err != nil {
 		t.Errorf("should not yield validUser as invalid, checkErr: %s", err)
 	} else {
 		t.Log("validated validUser")
@@ -40,7 +42,7 @@ func TestCheckStruct(t *testing.T) {
 		Username: "test",
 		Password: "test1234",
 	}
-	if checkErr := validate.Check(emptyNameWeakPassword); checkErr != nil {
+	if checkErr := validate.Check(context.Background(), emptyNameWeakPassword); checkErr != nil {
 		var ferr validate.FieldErrors
 		if !errors.As(checkErr, &ferr) {
 			t.Errorf("should return error of type FieldErrors")
@@ -68,3 +70,57 @@ func TestCheckStruct(t *testing.T) {
 		t.Error("should return error for emptyNameWeakPassword")
 	}
 }
+
+type product struct {
+	Link     string `json:"link" validate:"required,safeurl"`
+	Currency string `json:"currency" validate:"required,currency"`
+	Owner    string `json:"owner" validate:"required,username"`
+}
+
+func TestCustomRules(t *testing.T) {
+	if err := validate.Init(validate.Config{}); err != nil {
+		t.Fatalf("create validator: %s", err)
+	}
+
+	valid := product{Link: "https://shop.example.com/p/1", Currency: "EUR", Owner: "jane_doe"}
+	if err := validate.Check(context.Background(), valid); err != nil {
+		t.Errorf("should not yield valid product as invalid, checkErr: %s", err)
+	}
+
+	invalid := product{Link: "http://169.254.169.254/", Currency: "EURO", Owner: "admin"}
+
+	tests := []struct {
+		locale string
+		fields map[string]string
+	}{
+		{
+			locale: "en",
+			fields: map[string]string{
+				"link":     "link should be a public http or https URL",
+				"currency": "currency should be an ISO 4217 currency code such as USD",
+				"owner":    "owner is reserved, choose another one",
+			},
+		},
+		{
+			locale: "fr",
+			fields: map[string]string{
+				"link":     "link doit être une URL http ou https publique",
+				"currency": "currency doit être un code de devise ISO 4217 comme EUR",
+				"owner":    "owner est réservé, choisissez-en un autre",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			err := validate.Check(i18n.WithLocale(context.Background(), tt.locale), invalid)
+			var ferr validate.FieldErrors
+			if !errors.As(err, &ferr) {
+				t.Fatalf("should return FieldErrors, got: %v", err)
+			}
+			if got := ferr.Fields(); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("fields want %v got %v", tt.fields, got)
+			}
+		})
+	}
+}
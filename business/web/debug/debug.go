@@ -0,0 +1,27 @@
+// Package debug builds the mux of the debug listener which serves profiling, runtime
+// variables and metrics, it must not be reachable publicly
+package debug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/so-heil/wishlist/business/metrics"
+)
+
+// Mux registers pprof, expvar and metrics handlers on a new mux, it is used instead of
+// http.DefaultServeMux so importing net/http/pprof does not leak them elsewhere
+func Mux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", metrics.Handler())
+
+	return mux
+}
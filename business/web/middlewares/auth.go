@@ -18,6 +18,7 @@ func Auth(a *auth.Auth) web.Middleware {
 			}
 
 			ctx = auth.SetUserID(ctx, uc.ID)
+			web.AddLogFields(ctx, "userID", uc.ID)
 			return handler(ctx, w, r)
 		}
 		return h
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+// BodyLimit caps request bodies to maxBytes, reading past the limit fails and web.DecodeBody
+// answers it with 413. Routes can tighten the limit with a BodyLimit of their own.
+func BodyLimit(maxBytes int64) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
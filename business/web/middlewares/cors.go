@@ -0,0 +1,222 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+// CORSPolicy describes the cross-origin requests allowed on a route
+type CORSPolicy struct {
+	// AllowedOrigins are exact origins, "*" for any origin, or origins with a wildcard
+	// subdomain such as https://*.example.com which does not match https://example.com itself.
+	// Any origin can not be allowed with credentials as it lets every site act for the user.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORSConfig holds the policy of all routes, routes can have their own policy keyed
+// by the pattern they are registered with, e.g. /wishlists/{id}
+type CORSConfig struct {
+	Default CORSPolicy
+	Routes  map[string]CORSPolicy
+}
+
+// errCORSAnyOriginCredentials is returned for policies allowing credentials for any origin
+var errCORSAnyOriginCredentials = errors.New("credentials can not be allowed for any origin")
+
+// CORS answers preflight requests and adds the CORS headers to the responses of allowed
+// origins, as it relies on the OPTIONS routes of web.App it has to be an app middleware
+func CORS(cfg CORSConfig) (web.Middleware, error) {
+	def, err := newCORSPolicy(cfg.Default)
+	if err != nil {
+		return nil, fmt.Errorf("default cors policy: %w", err)
+	}
+	routes := make(map[string]corsPolicy, len(cfg.Routes))
+	for route, p := range cfg.Routes {
+		if routes[route], err = newCORSPolicy(p); err != nil {
+			return nil, fmt.Errorf("cors policy of %s: %w", route, err)
+		}
+	}
+
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return handler(ctx, w, r)
+			}
+
+			policy, ok := routes[web.GetValues(ctx).Route]
+			if !ok {
+				policy = def
+			}
+
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if !policy.allowsOrigin(origin) {
+				return handler(ctx, w, r)
+			}
+
+			if !preflight {
+				policy.setOrigin(w.Header(), origin)
+				if len(policy.exposed) != 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.exposed, ", "))
+				}
+				return handler(ctx, w, r)
+			}
+
+			// Preflights of disallowed methods or headers are answered without CORS headers
+			// so the browser rejects the actual request
+			reqHeaders := splitHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+			if !policy.allowsMethod(r.Header.Get("Access-Control-Request-Method")) || !policy.allowsHeaders(reqHeaders) {
+				return web.Respond(w, ctx, nil, http.StatusNoContent)
+			}
+
+			policy.setOrigin(w.Header(), origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.methods, ", "))
+			if len(reqHeaders) != 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+			}
+			if policy.maxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.maxAge.Seconds())))
+			}
+
+			return web.Respond(w, ctx, nil, http.StatusNoContent)
+		}
+
+		return h
+	}
+
+	return m, nil
+}
+
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     []string
+	wildcards   [][2]string
+	methods     []string
+	anyHeader   bool
+	headers     []string
+	exposed     []string
+	credentials bool
+	maxAge      time.Duration
+}
+
+func newCORSPolicy(p CORSPolicy) (corsPolicy, error) {
+	cp := corsPolicy{
+		exposed:     p.ExposedHeaders,
+		credentials: p.AllowCredentials,
+		maxAge:      p.MaxAge,
+	}
+
+	for _, o := range p.AllowedOrigins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		switch {
+		case o == "*":
+			cp.anyOrigin = true
+		case strings.Contains(o, "://*."):
+			prefix, suffix, _ := strings.Cut(o, "*")
+			cp.wildcards = append(cp.wildcards, [2]string{prefix, suffix})
+		case o != "":
+			cp.origins = append(cp.origins, o)
+		}
+	}
+
+	cp.methods = slices.Clone(p.AllowedMethods)
+	if len(cp.methods) == 0 {
+		cp.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	for i := range cp.methods {
+		cp.methods[i] = strings.ToUpper(cp.methods[i])
+	}
+
+	for _, h := range p.AllowedHeaders {
+		if h == "*" {
+			cp.anyHeader = true
+			continue
+		}
+		cp.headers = append(cp.headers, http.CanonicalHeaderKey(strings.TrimSpace(h)))
+	}
+
+	if cp.anyOrigin && cp.credentials {
+		return corsPolicy{}, errCORSAnyOriginCredentials
+	}
+
+	return cp, nil
+}
+
+func (cp corsPolicy) allowsOrigin(origin string) bool {
+	if cp.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if slices.Contains(cp.origins, origin) {
+		return true
+	}
+
+	for _, wc := range cp.wildcards {
+		prefix, suffix := wc[0], wc[1]
+		if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cp corsPolicy) allowsMethod(method string) bool {
+	return slices.Contains(cp.methods, strings.ToUpper(method))
+}
+
+func (cp corsPolicy) allowsHeaders(headers []string) bool {
+	if cp.anyHeader {
+		return true
+	}
+	for _, h := range headers {
+		if !slices.Contains(cp.headers, http.CanonicalHeaderKey(h)) {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin echoes the origin unless any origin is allowed, policies allowing any origin
+// have no credentials
+func (cp corsPolicy) setOrigin(h http.Header, origin string) {
+	if cp.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	h.Set("Access-Control-Allow-Origin", origin)
+	if cp.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func splitHeaderList(list string) []string {
+	var headers []string
+	for _, h := range strings.Split(list, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,161 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+func TestCORS(t *testing.T) {
+	l := zap.NewNop().Sugar()
+	cors, err := middlewares.CORS(middlewares.CORSConfig{
+		Default: middlewares.CORSPolicy{
+			AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+			MaxAge:         10 * time.Minute,
+		},
+		Routes: map[string]middlewares.CORSPolicy{
+			"/public/{id}": {AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("should create cors middleware: %s", err)
+	}
+
+	app := web.NewApp(l, http.NewServeMux(), []web.Middleware{middlewares.Errors(l), cors}, make(chan os.Signal, 1), noop.NewTracerProvider().Tracer(""))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(w, ctx, nil, http.StatusNoContent)
+	}
+	app.Handle(http.MethodPost, "users", "/login", ok)
+	app.Handle(http.MethodGet, "public", "/{id}", ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		headers     map[string]string
+		status      int
+		allowOrigin string
+		allowHeader string
+		maxAge      string
+	}{
+		{
+			name:        "preflight",
+			method:      http.MethodOptions,
+			path:        "/users/login",
+			headers:     map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type"},
+			status:      http.StatusNoContent,
+			allowOrigin: "https://app.example.com",
+			allowHeader: "content-type",
+			maxAge:      "600",
+		},
+		{
+			name:        "preflightWildcardSubdomain",
+			method:      http.MethodOptions,
+			path:        "/users/login",
+			headers:     map[string]string{"Origin": "https://eu.example.org", "Access-Control-Request-Method": "POST"},
+			status:      http.StatusNoContent,
+			allowOrigin: "https://eu.example.org",
+			maxAge:      "600",
+		},
+		{
+			name:    "preflightWildcardBareDomain",
+			method:  http.MethodOptions,
+			path:    "/users/login",
+			headers: map[string]string{"Origin": "https://example.org", "Access-Control-Request-Method": "POST"},
+			status:  http.StatusNoContent,
+		},
+		{
+			name:    "preflightDisallowedMethod",
+			method:  http.MethodOptions,
+			path:    "/users/login",
+			headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			status:  http.StatusNoContent,
+		},
+		{
+			name:    "preflightDisallowedHeader",
+			method:  http.MethodOptions,
+			path:    "/users/login",
+			headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "X-Secret"},
+			status:  http.StatusNoContent,
+		},
+		{
+			name:        "actualRequest",
+			method:      http.MethodPost,
+			path:        "/users/login",
+			headers:     map[string]string{"Origin": "https://app.example.com"},
+			status:      http.StatusNoContent,
+			allowOrigin: "https://app.example.com",
+		},
+		{
+			name:    "disallowedOrigin",
+			method:  http.MethodPost,
+			path:    "/users/login",
+			headers: map[string]string{"Origin": "https://evil.com"},
+			status:  http.StatusNoContent,
+		},
+		{
+			name:        "routePolicy",
+			method:      http.MethodOptions,
+			path:        "/public/12",
+			headers:     map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Anything"},
+			status:      http.StatusNoContent,
+			allowOrigin: "*",
+			allowHeader: "X-Anything",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("create request: %s", err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("should be able to call handler over http: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("status want %d got %d", tt.status, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin want %q got %q", tt.allowOrigin, got)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Headers"); got != tt.allowHeader {
+				t.Errorf("Access-Control-Allow-Headers want %q got %q", tt.allowHeader, got)
+			}
+			if got := resp.Header.Get("Access-Control-Max-Age"); got != tt.maxAge {
+				t.Errorf("Access-Control-Max-Age want %q got %q", tt.maxAge, got)
+			}
+		})
+	}
+}
+
+func TestCORSAnyOriginCredentials(t *testing.T) {
+	anyOrigin := middlewares.CORSPolicy{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}
+
+	if _, err := middlewares.CORS(middlewares.CORSConfig{Default: anyOrigin}); err == nil {
+		t.Error("should reject allowing credentials for any origin")
+	}
+	if _, err := middlewares.CORS(middlewares.CORSConfig{Routes: map[string]middlewares.CORSPolicy{"/public/{id}": anyOrigin}}); err == nil {
+		t.Error("should reject route policies allowing credentials for any origin")
+	}
+}
@@ -5,49 +5,37 @@ import (
 	"errors"
 	"net/http"
 
-	"github.com/so-heil/wishlist/business/validate"
+	"github.com/so-heil/wishlist/business/web/problems"
 	"github.com/so-heil/wishlist/foundation/web"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// Errors logs errors returned by handlers and answers them with problem details, see
+// problems.From, shutdown errors are returned after the response to stop the app
 func Errors(l *zap.SugaredLogger) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			if err := handler(ctx, w, r); err != nil {
-				l.Errorln(err)
+				l := web.GetLogger(ctx, l)
+				var perr PanicError
+				if errors.As(err, &perr) {
+					l.Errorw(perr.Error(), "traceID", web.GetTraceID(ctx), "stack", string(perr.Stack))
+				} else {
+					l.Errorln(err)
+				}
 
 				var span trace.Span
 				ctx, span = web.AddSpan(ctx, "web.request.middlewares.error")
 				span.RecordError(err)
 				span.End()
 
-				var eue web.EndUserError
-
-				switch {
-				case web.IsEndUserError(err):
-					errors.As(err, &eue)
-				case validate.IsFieldErrors(err):
-					var ferr validate.FieldErrors
-					errors.As(err, &ferr)
-					eue = web.EndUserError{
-						Message: "some fileds have invalid values",
-						Status:  http.StatusBadRequest,
-						Fields:  ferr.Fields(),
-					}
-				case web.IsExternalError(err):
-					eue = web.EndUserError{
-						Message: "some services are not available",
-						Status:  http.StatusServiceUnavailable,
-					}
-				default:
-					eue = web.EndUserError{
-						Message: "something went really wrong",
-						Status:  http.StatusInternalServerError,
-					}
+				if err := web.RespondProblem(w, ctx, problems.From(ctx, err)); err != nil {
+					return err
 				}
 
-				if err := web.Respond(w, ctx, eue, eue.Status); err != nil {
+				// Let the app shut down after the client got its response
+				if web.IsShutdown(err) {
 					return err
 				}
 			}
@@ -0,0 +1,267 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/so-heil/wishlist/business/auth"
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.uber.org/zap"
+)
+
+// Headers of Idempotency, replayed responses carry IdempotentReplayedHeader
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// Problem codes of Idempotency, route docs list them for the routes using it
+const (
+	CodeInvalidIdempotencyKey = "request.invalid_idempotency_key"
+	CodeIdempotencyInProgress = "request.idempotency_in_progress"
+	CodeIdempotencyKeyReused  = "request.idempotency_key_reused"
+)
+
+const maxIdempotencyKeyLen = 255
+
+// IdempotencyMinEntrySize is the size of the entries the store of Idempotency should take
+// at least, responses stored with their headers beyond what the store takes are not replayed
+const IdempotencyMinEntrySize = 32 << 10
+
+// IdempotencyConfig sets how long responses are kept for retries
+type IdempotencyConfig struct {
+	// TTL is how long the first response is replayed for retries with the same key
+	TTL time.Duration
+	// LockTTL bounds how long a request holds its key while being handled, it should be
+	// longer than the write timeout so a crashed instance does not keep the key forever
+	LockTTL time.Duration
+}
+
+// idempotentResponse is stored by the key of the request, it has no status until the first
+// request is answered
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// Idempotency makes the route safe to retry for requests with an Idempotency-Key header.
+// The first response of a key is stored for the user and the path and replayed for its
+// retries, retries while the first request is in flight are answered with 409 and reusing
+// the key with a different body with 422. Handler errors and server errors are not stored
+// so the request can be retried after them. Requests without the header pass through, as
+// do anonymous requests since there is no caller to scope their keys to.
+func Idempotency(l *zap.SugaredLogger, store keyvalue.KeyValueStore, cfg IdempotencyConfig) web.Middleware {
+	responses := keyvalue.NewTyped[idempotentResponse](store, nil)
+
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			principal := idempotencyPrincipal(ctx, r)
+			if key == "" || principal == "" {
+				return handler(ctx, w, r)
+			}
+			if !validIdempotencyKey(key) {
+				return web.EndUserError{
+					Message: fmt.Sprintf("%s header should have 1 to %d printable characters", IdempotencyKeyHeader, maxIdempotencyKeyLen),
+					Code:    CodeInvalidIdempotencyKey,
+					Status:  http.StatusBadRequest,
+					Params:  []string{strconv.Itoa(maxIdempotencyKeyLen)},
+				}
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				// Let the handler fail on the body the way it does without the header
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+				return handler(ctx, w, r)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.Sum256(body)
+			fingerprint := hex.EncodeToString(sum[:])
+			storeKey := idempotencyKey(r, principal, key)
+
+			set, err := responses.SetNX(storeKey, idempotentResponse{Fingerprint: fingerprint}, cfg.LockTTL)
+			if err != nil {
+				return fmt.Errorf("lock idempotency key: %w", err)
+			}
+			if !set {
+				stored, err := responses.Get(storeKey)
+				if err != nil {
+					if errors.Is(err, keyvalue.ErrNotFound) {
+						// The first request failed or its response expired just now
+						return idempotencyInProgress(w)
+					}
+					return fmt.Errorf("get idempotent response: %w", err)
+				}
+				switch {
+				case stored.Fingerprint != fingerprint:
+					return web.EndUserError{
+						Message: fmt.Sprintf("%s has been used for a request with another body", IdempotencyKeyHeader),
+						Code:    CodeIdempotencyKeyReused,
+						Status:  http.StatusUnprocessableEntity,
+					}
+				case stored.Status == 0:
+					return idempotencyInProgress(w)
+				default:
+					return replay(ctx, w, stored)
+				}
+			}
+
+			// Replays may go to clients accepting other encodings, so the response is not compressed
+			r.Header.Del("Accept-Encoding")
+
+			rec := newRecorder(w)
+			if err := handler(ctx, rec, r); err != nil {
+				responses.Del(storeKey)
+				return err
+			}
+
+			if rec.status >= http.StatusInternalServerError {
+				responses.Del(storeKey)
+				return nil
+			}
+
+			if err := responses.Set(storeKey, rec.response(fingerprint), cfg.TTL); err != nil {
+				// The client already has its response, a retry runs the handler again
+				responses.Del(storeKey)
+				web.GetLogger(ctx, l).Errorw("store idempotent response", "traceID", web.GetTraceID(ctx), "ERROR", err)
+			}
+
+			return nil
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// validIdempotencyKey accepts printable ASCII keys such as UUIDs
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyPrincipal is the caller the keys are scoped to, requests before authentication
+// are scoped by their Authorization header and anonymous requests have none
+func idempotencyPrincipal(ctx context.Context, r *http.Request) string {
+	if id, err := auth.GetUserID(ctx); err == nil {
+		return "user " + strconv.Itoa(id)
+	}
+	return r.Header.Get("Authorization")
+}
+
+// idempotencyKey scopes the key to the caller and the path so clients generating keys
+// independently do not collide
+func idempotencyKey(r *http.Request, principal, key string) string {
+	h := sha256.New()
+	for _, part := range []string{principal, r.Method, r.URL.Path, key} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func idempotencyInProgress(w http.ResponseWriter) error {
+	w.Header().Set("Retry-After", "1")
+	return web.EndUserError{
+		Message: fmt.Sprintf("a request with the same %s is being handled", IdempotencyKeyHeader),
+		Code:    CodeIdempotencyInProgress,
+		Status:  http.StatusConflict,
+	}
+}
+
+func replay(ctx context.Context, w http.ResponseWriter, stored idempotentResponse) error {
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+
+	web.SetStatusCode(ctx, stored.Status)
+	w.WriteHeader(stored.Status)
+	if _, err := w.Write(stored.Body); err != nil {
+		return err
+	}
+	return nil
+}
+
+// recorder writes the response through while keeping a copy of it, only the headers set
+// by the handler are kept as the outer middlewares set theirs again on replay
+type recorder struct {
+	http.ResponseWriter
+	before http.Header
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder(w http.ResponseWriter) *recorder {
+	return &recorder{ResponseWriter: w, before: w.Header().Clone()}
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = make(http.Header)
+		for name, values := range rec.Header() {
+			if !slices.Equal(rec.before[name], values) {
+				rec.header[name] = slices.Clone(values)
+			}
+		}
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *recorder) response(fingerprint string) idempotentResponse {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return idempotentResponse{
+		Fingerprint: fingerprint,
+		Status:      status,
+		Header:      rec.header,
+		Body:        rec.body.Bytes(),
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (er errReader) Read([]byte) (int, error) {
+	return 0, er.err
+}
@@ -0,0 +1,213 @@
+package middlewares_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+func TestIdempotency(t *testing.T) {
+	l := zap.NewNop().Sugar()
+	idempotent := middlewares.Idempotency(l, kvstores.NewFreeCache(64<<20), middlewares.IdempotencyConfig{
+		TTL:     time.Minute,
+		LockTTL: 10 * time.Second,
+	})
+
+	app := web.NewApp(l, http.NewServeMux(), []web.Middleware{middlewares.Errors(l)}, make(chan os.Signal, 1), noop.NewTracerProvider().Tracer(""))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	var (
+		created atomic.Int64
+		calls   atomic.Int64
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	app.Handle(http.MethodPost, "items", "", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var item struct {
+			Name string `json:"name"`
+		}
+		if err := web.DecodeBody(ctx, r, &item); err != nil {
+			return err
+		}
+		if item.Name == "slow" {
+			started <- struct{}{}
+			<-release
+		}
+		w.Header().Set("Location", "/items/"+item.Name)
+		return web.Respond(w, ctx, struct {
+			ID int64 `json:"id"`
+		}{ID: created.Add(1)}, http.StatusCreated)
+	}, idempotent)
+	app.Handle(http.MethodPost, "items", "/flaky", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if calls.Add(1) == 1 {
+			return web.EndUserError{Message: "try again", Status: http.StatusBadRequest}
+		}
+		return web.Respond(w, ctx, nil, http.StatusNoContent)
+	}, idempotent)
+
+	app.Handle(http.MethodPost, "items", "/large", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(w, ctx, struct {
+			ID   int64  `json:"id"`
+			Note string `json:"note"`
+		}{ID: created.Add(1), Note: strings.Repeat("n", 4<<10)}, http.StatusCreated)
+	}, idempotent)
+
+	url := srv.URL + "/items"
+
+	t.Run("withoutKey", func(t *testing.T) {
+		postItem(t, url, "", `{"name":"one"}`)
+		postItem(t, url, "", `{"name":"one"}`)
+		if got := created.Load(); got != 2 {
+			t.Fatalf("requests without a key should not be deduplicated, created: %d", got)
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		postItem(t, url, "key-0", `{"name":"one"}`, "Authorization", "")
+		resp, _ := postItem(t, url, "key-0", `{"name":"one"}`, "Authorization", "")
+		if resp.Header.Get(middlewares.IdempotentReplayedHeader) != "" {
+			t.Error("requests without a caller should not be replayed")
+		}
+		if got := created.Load(); got != 4 {
+			t.Fatalf("requests without a caller should not be deduplicated, created: %d", got)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		first, firstBody := postItem(t, url, "key-1", `{"name":"one"}`)
+		if first.StatusCode != http.StatusCreated || first.Header.Get(middlewares.IdempotentReplayedHeader) != "" {
+			t.Fatalf("first request should be handled, status: %d", first.StatusCode)
+		}
+
+		retry, retryBody := postItem(t, url, "key-1", `{"name":"one"}`)
+		if retry.StatusCode != http.StatusCreated || retryBody != firstBody {
+			t.Errorf("retry should replay %d %s, got: %d %s", first.StatusCode, firstBody, retry.StatusCode, retryBody)
+		}
+		if retry.Header.Get("Location") != "/items/one" || retry.Header.Get(middlewares.IdempotentReplayedHeader) != "true" {
+			t.Errorf("retry should replay the headers of the handler, got: %v", retry.Header)
+		}
+		if got := created.Load(); got != 5 {
+			t.Errorf("retry should not run the handler, created: %d", got)
+		}
+
+		other, _ := postItem(t, url, "key-1", `{"name":"one"}`, "Authorization", "Bearer other")
+		if other.StatusCode != http.StatusCreated || other.Header.Get(middlewares.IdempotentReplayedHeader) != "" {
+			t.Errorf("keys should be scoped to the caller, status: %d", other.StatusCode)
+		}
+	})
+
+	t.Run("largeResponse", func(t *testing.T) {
+		first, firstBody := postItem(t, url+"/large", "key-4", `{}`)
+		if first.StatusCode != http.StatusCreated || len(firstBody) <= 4<<10 {
+			t.Fatalf("first request should be handled, status: %d", first.StatusCode)
+		}
+
+		retry, retryBody := postItem(t, url+"/large", "key-4", `{}`)
+		if retry.Header.Get(middlewares.IdempotentReplayedHeader) != "true" || retryBody != firstBody {
+			t.Errorf("retry should replay responses over 512 bytes, got: %d %.64s", retry.StatusCode, retryBody)
+		}
+	})
+
+	t.Run("reused", func(t *testing.T) {
+		resp, body := postItem(t, url, "key-1", `{"name":"two"}`)
+		if resp.StatusCode != http.StatusUnprocessableEntity || problemCode(t, body) != middlewares.CodeIdempotencyKeyReused {
+			t.Errorf("reusing a key with another body should be rejected, got: %d %s", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		resp, body := postItem(t, url, strings.Repeat("k", 256), `{"name":"one"}`)
+		if resp.StatusCode != http.StatusBadRequest || problemCode(t, body) != middlewares.CodeInvalidIdempotencyKey {
+			t.Errorf("long keys should be rejected, got: %d %s", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("inFlight", func(t *testing.T) {
+		done := make(chan *http.Response)
+		go func() {
+			resp, _ := postItem(t, url, "key-2", `{"name":"slow"}`)
+			done <- resp
+		}()
+		<-started
+
+		resp, body := postItem(t, url, "key-2", `{"name":"slow"}`)
+		if resp.StatusCode != http.StatusConflict || problemCode(t, body) != middlewares.CodeIdempotencyInProgress {
+			t.Errorf("concurrent duplicates should be rejected, got: %d %s", resp.StatusCode, body)
+		}
+		if resp.Header.Get("Retry-After") == "" {
+			t.Error("concurrent duplicates should be told when to retry")
+		}
+
+		close(release)
+		if first := <-done; first.StatusCode != http.StatusCreated {
+			t.Errorf("first request should be handled, status: %d", first.StatusCode)
+		}
+	})
+
+	t.Run("errorsNotStored", func(t *testing.T) {
+		if resp, _ := postItem(t, url+"/flaky", "key-3", `{}`); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("first request should fail, status: %d", resp.StatusCode)
+		}
+		if resp, _ := postItem(t, url+"/flaky", "key-3", `{}`); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("retry after an error should run the handler, status: %d", resp.StatusCode)
+		}
+	})
+}
+
+func postItem(t *testing.T, url, key, body string, headers ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("create request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test")
+	if key != "" {
+		req.Header.Set(middlewares.IdempotencyKeyHeader, key)
+	}
+	// Empty values remove the header
+	for i := 0; i+1 < len(headers); i += 2 {
+		if headers[i+1] == "" {
+			req.Header.Del(headers[i])
+			continue
+		}
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("should be able to call handler over http: %s", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read response: %s", err)
+	}
+	return resp, string(respBody)
+}
+
+func problemCode(t *testing.T, body string) string {
+	t.Helper()
+
+	var p web.Problem
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatalf("decode problem %q: %s", body, err)
+	}
+	return p.Code
+}
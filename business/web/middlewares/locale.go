@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/so-heil/wishlist/business/i18n"
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+// Locale picks the locale of the request from its Accept-Language header and carries it in
+// the context, see i18n.Locale. It has to come before Errors so problems are translated.
+func Locale() web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			locale := i18n.Match(r.Header.Get("Accept-Language"))
+
+			w.Header().Set("Content-Language", locale)
+			w.Header().Add("Vary", "Accept-Language")
+
+			return handler(i18n.WithLocale(ctx, locale), w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,85 @@
+package middlewares_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/so-heil/wishlist/business/entities/user"
+	"github.com/so-heil/wishlist/business/validate"
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+func TestLocale(t *testing.T) {
+	if err := validate.Init(validate.Config{}); err != nil {
+		t.Fatalf("init validator: %s", err)
+	}
+
+	l := zap.NewNop().Sugar()
+	app := web.NewApp(l, http.NewServeMux(), []web.Middleware{middlewares.Locale(), middlewares.Errors(l)}, make(chan os.Signal, 1), noop.NewTracerProvider().Tracer(""))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	app.Handle(http.MethodGet, "localetest", "/taken", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.EUEFromError(user.ErrUniqueEmail, http.StatusBadRequest)
+	})
+	app.Handle(http.MethodGet, "localetest", "/invalid", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return validate.Check(ctx, struct {
+			Email string `json:"email" validate:"required"`
+		}{})
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptLanguage string
+		locale         string
+		detail         string
+		field          string
+	}{
+		{name: "default", path: "/taken", locale: "en", detail: user.ErrUniqueEmail.Error()},
+		{name: "unsupported", path: "/taken", acceptLanguage: "de-DE,de;q=0.9", locale: "en", detail: user.ErrUniqueEmail.Error()},
+		{name: "french", path: "/taken", acceptLanguage: "fr-CH, fr;q=0.9, en;q=0.8", locale: "fr", detail: "cet e-mail est déjà enregistré"},
+		{name: "persian", path: "/taken", acceptLanguage: "fa-IR", locale: "fa", detail: "این ایمیل قبلا ثبت شده است"},
+		{name: "validation", path: "/invalid", acceptLanguage: "fr", locale: "fr", detail: "certains champs ont des valeurs invalides", field: "email est un champ obligatoire"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/localetest"+tt.path, nil)
+			if err != nil {
+				t.Fatalf("create request: %s", err)
+			}
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("should be able to call handler over http: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Language"); got != tt.locale {
+				t.Errorf("Content-Language want %q got %q", tt.locale, got)
+			}
+
+			var p web.Problem
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("decode problem: %s", err)
+			}
+			if p.Detail != tt.detail {
+				t.Errorf("detail want %q got %q", tt.detail, p.Detail)
+			}
+			if tt.field != "" && p.Fields["email"] != tt.field {
+				t.Errorf("email field want %q got %q", tt.field, p.Fields["email"])
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"time"
 
@@ -10,31 +11,73 @@ import (
 	"go.uber.org/zap"
 )
 
-func Log(log *zap.SugaredLogger) web.Middleware {
+// LogConfig configures request logs
+type LogConfig struct {
+	// SuccessSampleRate is the ratio of successful requests logged, between 0 and 1,
+	// requests failing with an error or a 4xx/5xx status code are always logged
+	SuccessSampleRate float64
+}
+
+// Log sets a logger carrying the request id and trace id on the request, see web.GetLogger,
+// and logs the start and the end of requests
+func Log(log *zap.SugaredLogger, cfg LogConfig) web.Middleware {
 	return func(handler web.Handler) web.Handler {
 		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-			log.Infow("request started", "method", r.Method, "path", r.URL.Path, "traceID", web.GetTraceID(ctx))
+			v := web.GetValues(ctx)
+			l := log.With("requestID", v.RequestID, "traceID", v.TraceID)
+			web.SetLogger(ctx, l)
 
-			if err := handler(ctx, w, r); err != nil {
-				log.Errorw(fmt.Sprintf("request: %s", err), "method", r.Method, "path", r.URL.Path, "traceID", web.GetTraceID(ctx))
-				return err
+			// Sampling is decided upfront so sampled requests get both logs
+			sampled := cfg.SuccessSampleRate >= 1 || rand.Float64() < cfg.SuccessSampleRate
+			if sampled {
+				l.Infow(
+					"request started",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"remoteAddr", r.RemoteAddr,
+					"userAgent", r.UserAgent(),
+				)
 			}
 
-			v := web.GetValues(ctx)
-			log.Infow(
-				"request ended",
-				"method",
-				r.Method,
-				"path",
-				r.URL.Path,
-				"traceID",
-				web.GetTraceID(ctx),
-				"statusCode",
-				v.StatusCode,
-				"took",
-				time.Since(v.Now).String(),
-			)
-			return nil
+			cw := &countingWriter{ResponseWriter: w}
+			err := handler(ctx, cw, r)
+
+			// Fields added while handling, e.g. the user id, are on the request logger
+			l = web.GetLogger(ctx, l)
+			fields := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", v.Route,
+				"statusCode", v.StatusCode,
+				"bytes", cw.n,
+				"took", time.Since(v.Now).String(),
+			}
+
+			switch {
+			case err != nil:
+				l.Errorw(fmt.Sprintf("request: %s", err), fields...)
+			case sampled || v.StatusCode >= http.StatusBadRequest:
+				l.Infow("request ended", fields...)
+			}
+
+			return err
 		}
 	}
 }
+
+// countingWriter counts the bytes of the response body
+type countingWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.n += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (cw *countingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
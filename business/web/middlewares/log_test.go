@@ -0,0 +1,92 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := zap.New(core).Sugar()
+
+	app := web.NewApp(
+		l,
+		http.NewServeMux(),
+		[]web.Middleware{middlewares.RequestID(), middlewares.Log(l, middlewares.LogConfig{SuccessSampleRate: 0})},
+		make(chan os.Signal, 1),
+		noop.NewTracerProvider().Tracer(""),
+	)
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	app.Handle(http.MethodGet, "logtest", "/{status}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		web.AddLogFields(ctx, "userID", 7)
+		web.GetLogger(ctx, l).Infow("handling")
+		switch web.Param(r, "status") {
+		case "fail":
+			return errors.New("failed")
+		case "missing":
+			return web.Respond(w, ctx, "not found", http.StatusNotFound)
+		}
+		return web.Respond(w, ctx, "ok", http.StatusOK)
+	})
+
+	call := func(path, requestID string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			t.Fatalf("create request: %s", err)
+		}
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("should be able to call handler over http: %s", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := call("/logtest/ok", "caller-id-1"); resp.Header.Get("X-Request-ID") != "caller-id-1" {
+		t.Errorf("should echo the request id of the caller, got: %q", resp.Header.Get("X-Request-ID"))
+	}
+	if resp := call("/logtest/ok", "bad id"); resp.Header.Get("X-Request-ID") == "" {
+		t.Error("should generate a request id in place of an invalid one")
+	}
+	call("/logtest/missing", "caller-id-2")
+	call("/logtest/fail", "caller-id-3")
+
+	if n := logs.FilterMessage("request started").Len(); n != 0 {
+		t.Errorf("should not log unsampled requests starting, got %d logs", n)
+	}
+
+	ended := logs.FilterMessage("request ended").AllUntimed()
+	if len(ended) != 1 {
+		t.Fatalf("should only log the end of the failed request, got %d logs", len(ended))
+	}
+	fields := ended[0].ContextMap()
+	if fields["requestID"] != "caller-id-2" || fields["userID"] != int64(7) || fields["statusCode"] != int64(http.StatusNotFound) {
+		t.Errorf("end log should carry request fields, got: %v", fields)
+	}
+	if fields["bytes"].(int64) == 0 {
+		t.Error("end log should carry the response size")
+	}
+
+	if n := logs.FilterMessage("request: failed").FilterField(zap.String("requestID", "caller-id-3")).Len(); n != 1 {
+		t.Errorf("should always log errors, got %d logs", n)
+	}
+	if n := logs.FilterMessage("handling").FilterField(zap.String("requestID", "caller-id-1")).Len(); n != 1 {
+		t.Errorf("handlers should log with the request fields, got %d logs", n)
+	}
+}
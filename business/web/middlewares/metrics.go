@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/so-heil/wishlist/business/metrics"
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+// Metrics records request count, latency and in-flight requests by route template and the
+// status code set in web.Values, so it should wrap Errors to see the status of failed requests
+func Metrics() web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v := web.GetValues(ctx)
+			done := metrics.RequestStarted(r.Method, v.Route, v.Now)
+
+			err := handler(ctx, w, r)
+
+			status := v.StatusCode
+			switch {
+			case err != nil:
+				// Errors escaping this far are answered by web.App with a 500
+				status = http.StatusInternalServerError
+			case status == 0:
+				// net/http answers handlers that wrote nothing with a 200
+				status = http.StatusOK
+			}
+			done(status)
+
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,62 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/so-heil/wishlist/business/metrics"
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+func TestMetrics(t *testing.T) {
+	l := zap.NewNop().Sugar()
+	app := web.NewApp(l, http.NewServeMux(), []web.Middleware{middlewares.Metrics(), middlewares.Errors(l)}, make(chan os.Signal, 1), noop.NewTracerProvider().Tracer(""))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	app.Handle(http.MethodGet, "metricstest", "/{id}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		switch web.Param(r, "id") {
+		case "fail":
+			return errors.New("failed")
+		case "silent":
+			return nil
+		}
+		return web.Respond(w, ctx, nil, http.StatusNoContent)
+	})
+
+	for _, id := range []string{"1", "2", "fail", "silent"} {
+		resp, err := http.Get(srv.URL + "/metricstest/" + id)
+		if err != nil {
+			t.Fatalf("should be able to call handler over http: %s", err)
+		}
+		resp.Body.Close()
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("read metrics: %s", err)
+	}
+
+	for _, want := range []string{
+		`wishapi_http_requests_total{method="GET",route="/metricstest/{id}",status="204"} 2`,
+		`wishapi_http_requests_total{method="GET",route="/metricstest/{id}",status="500"} 1`,
+		`wishapi_http_requests_total{method="GET",route="/metricstest/{id}",status="200"} 1`,
+		`wishapi_http_request_duration_seconds_count{method="GET",route="/metricstest/{id}",status="204"} 2`,
+		`wishapi_http_requests_in_flight{route="/metricstest/{id}"} 0`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics should contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PanicError is a recovered panic along with the stack trace of the goroutine that panicked
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (pe PanicError) Error() string {
+	return fmt.Sprintf("PANIC [%v]", pe.Value)
+}
+
+// Panics recovers panics of the handlers it wraps and turns them into a PanicError, so it
+// should be registered after Errors to have the panic answered like any other error.
+// http.ErrAbortHandler is panicked again to let net/http abort the response.
+func Panics() web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) (err error) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// net/http aborts the response on purpose with it, it is not a bug to report
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				perr := PanicError{Value: rec, Stack: debug.Stack()}
+
+				span := trace.SpanFromContext(ctx)
+				span.RecordError(perr, trace.WithAttributes(
+					semconv.ExceptionStacktraceKey.String(string(perr.Stack)),
+				))
+				span.SetStatus(codes.Error, perr.Error())
+
+				err = perr
+			}()
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,98 @@
+package middlewares_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestPanics(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	l := zap.NewNop().Sugar()
+
+	app := web.NewApp(
+		l,
+		http.NewServeMux(),
+		[]web.Middleware{middlewares.Errors(l), middlewares.Panics()},
+		make(chan os.Signal, 1),
+		tp.Tracer("test"),
+	)
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	app.Handle(http.MethodGet, "test", "/panic", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+
+	resp, err := http.Get(srv.URL + "/test/panic")
+	if err != nil {
+		t.Fatalf("should be able to call handler over http: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("should have 500 status code, has: %s", resp.Status)
+	}
+
+	var body struct {
+		TraceID string `json:"instance"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("should be able to decode response: %s", err)
+	}
+
+	var handled bool
+	for _, span := range recorder.Ended() {
+		if span.Name() != "GET /test/panic" {
+			continue
+		}
+		handled = true
+		if span.SpanContext().TraceID().String() != body.TraceID {
+			t.Errorf("response trace id should match the span, want %s got %s", span.SpanContext().TraceID(), body.TraceID)
+		}
+		if span.Status().Code != codes.Error {
+			t.Errorf("span status should be error, got: %v", span.Status())
+		}
+		var stack bool
+		for _, event := range span.Events() {
+			for _, attr := range event.Attributes {
+				if attr.Key == "exception.stacktrace" && attr.Value.AsString() != "" {
+					stack = true
+				}
+			}
+		}
+		if !stack {
+			t.Error("span should record the panic with its stack trace")
+		}
+	}
+	if !handled {
+		t.Fatal("should have ended the request span")
+	}
+}
+
+func TestPanicsAbortHandler(t *testing.T) {
+	h := middlewares.Panics()(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("should panic again with http.ErrAbortHandler, got: %v", rec)
+		}
+	}()
+	h(context.Background(), httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("should not recover from http.ErrAbortHandler")
+}
@@ -0,0 +1,179 @@
+// Package problems maps business errors to problem types with stable codes, clients match
+// the code of a problem instead of its human-readable detail
+package problems
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/so-heil/wishlist/business/auth"
+	"github.com/so-heil/wishlist/business/entities/user"
+	"github.com/so-heil/wishlist/business/i18n"
+	"github.com/so-heil/wishlist/business/otp"
+	"github.com/so-heil/wishlist/business/validate"
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+// typeBase prefixes codes to make problem type URIs
+const typeBase = "urn:wishlist:problem:"
+
+// Definition describes a problem type, a code must never change once clients know it
+type Definition struct {
+	Code   string
+	Title  string
+	Status int
+}
+
+// Type is the URI of the problem type
+func (d Definition) Type() string {
+	return typeBase + d.Code
+}
+
+// Problem types that are not bound to a sentinel error
+var (
+	Validation  = Definition{Code: "validation.failed", Title: "Some fields have invalid values", Status: http.StatusBadRequest}
+	Unavailable = Definition{Code: "service.unavailable", Title: "Some services are not available", Status: http.StatusServiceUnavailable}
+	Internal    = Definition{Code: web.CodeInternal, Title: "Something went really wrong", Status: http.StatusInternalServerError}
+)
+
+var (
+	emailTaken       = Definition{Code: "user.email_taken", Title: "Email is already registered", Status: http.StatusBadRequest}
+	userNotFound     = Definition{Code: "user.not_found", Title: "User is not registered", Status: http.StatusNotFound}
+	wrongCredentials = Definition{Code: "user.wrong_credentials", Title: "Wrong credentials", Status: http.StatusUnauthorized}
+	resendTooSoon    = Definition{Code: "otp.resend_too_soon", Title: "A code has been sent lately", Status: http.StatusTooEarly}
+	invalidCode      = Definition{Code: "otp.invalid_code", Title: "Verification code is not valid", Status: http.StatusUnauthorized}
+	invalidToken     = Definition{Code: "auth.invalid_token", Title: "Token is not valid", Status: http.StatusUnauthorized}
+	malformedToken   = Definition{Code: "auth.malformed_token", Title: "Authorization header is malformed", Status: http.StatusUnauthorized}
+)
+
+type entry struct {
+	err error
+	def Definition
+}
+
+var (
+	mu       sync.RWMutex
+	registry = []entry{
+		{err: user.ErrUniqueEmail, def: emailTaken},
+		{err: user.ErrUserNotFound, def: userNotFound},
+		{err: user.ErrWrongCredentials, def: wrongCredentials},
+		{err: user.ErrEmailVerifySoon, def: resendTooSoon},
+		{err: otp.ErrCodeExists, def: resendTooSoon},
+		{err: user.ErrInvalidOTP, def: invalidCode},
+		{err: otp.ErrInvalidCode, def: invalidCode},
+		{err: auth.ErrInvalidToken, def: invalidToken},
+		{err: auth.ErrMalformedToken, def: malformedToken},
+	}
+)
+
+// Register maps the sentinel error to the definition, registering an error twice panics
+func Register(err error, def Definition) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, e := range registry {
+		if e.err == err {
+			panic(fmt.Sprintf("problems: error %q is already registered", err))
+		}
+	}
+	registry = append(registry, entry{err: err, def: def})
+}
+
+// Lookup returns the definition of the first registered error err matches with errors.Is
+func Lookup(err error) (Definition, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, e := range registry {
+		if errors.Is(err, e.err) {
+			return e.def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Codes returns the codes of the registered errors, it lets route docs list the problems
+// of a route without repeating codes, unregistered errors are skipped
+func Codes(errs ...error) []string {
+	var codes []string
+	for _, err := range errs {
+		if def, ok := Lookup(err); ok {
+			codes = append(codes, def.Code)
+		}
+	}
+	return codes
+}
+
+// From builds the problem of an error returned by a handler. Registered errors get their
+// definition, end user errors keep their message, status and code over it, validation
+// and external errors get their own types and anything else is an internal problem
+// without details. Title and detail are translated to the locale of ctx by the code of
+// the problem, see i18n.T.
+func From(ctx context.Context, err error) web.Problem {
+	var (
+		p      web.Problem
+		eue    web.EndUserError
+		params []string
+	)
+
+	def, registered := Lookup(err)
+	if registered {
+		p = web.Problem{
+			Type:   def.Type(),
+			Title:  def.Title,
+			Status: def.Status,
+			Detail: def.Title,
+			Code:   def.Code,
+		}
+	}
+
+	switch {
+	case errors.As(err, &eue):
+		p.Detail = eue.Message
+		p.Fields = eue.Fields
+		params = eue.Params
+		if eue.Status != 0 {
+			p.Status = eue.Status
+		}
+		if eue.Code != "" {
+			p.Code = eue.Code
+		}
+		if !registered && p.Code != "" {
+			p.Type = typeBase + p.Code
+		}
+	case registered:
+	case validate.IsFieldErrors(err):
+		var ferr validate.FieldErrors
+		errors.As(err, &ferr)
+		p = problem(Validation, "some fields have invalid values")
+		p.Fields = ferr.Fields()
+	case web.IsExternalError(err):
+		p = problem(Unavailable, "some services are not available")
+	default:
+		p = problem(Internal, "something went really wrong")
+	}
+
+	if p.Code != "" {
+		if title, ok := i18n.T(ctx, p.Code+".title"); ok {
+			p.Title = title
+		}
+		if detail, ok := i18n.T(ctx, p.Code+".detail", params...); ok {
+			p.Detail = detail
+		}
+	}
+
+	return p
+}
+
+func problem(def Definition, detail string) web.Problem {
+	return web.Problem{
+		Type:   def.Type(),
+		Title:  def.Title,
+		Status: def.Status,
+		Detail: detail,
+		Code:   def.Code,
+	}
+}
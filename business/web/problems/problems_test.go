@@ -0,0 +1,63 @@
+package problems_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/so-heil/wishlist/business/entities/user"
+	"github.com/so-heil/wishlist/business/otp"
+	"github.com/so-heil/wishlist/business/validate"
+	"github.com/so-heil/wishlist/business/web/problems"
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+func TestFrom(t *testing.T) {
+	errCustom := errors.New("custom")
+	problems.Register(errCustom, problems.Definition{Code: "test.custom", Title: "Custom", Status: http.StatusConflict})
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{name: "sentinel", err: fmt.Errorf("check: %w", otp.ErrInvalidCode), status: http.StatusUnauthorized, code: "otp.invalid_code", detail: "Verification code is not valid"},
+		{name: "endUserSentinel", err: web.EUEFromError(user.ErrUniqueEmail, http.StatusBadRequest), status: http.StatusBadRequest, code: "user.email_taken", detail: user.ErrUniqueEmail.Error()},
+		{name: "endUserStatus", err: web.EUEFromError(user.ErrUserNotFound, http.StatusBadRequest), status: http.StatusBadRequest, code: "user.not_found"},
+		{name: "endUserCode", err: web.EndUserError{Message: "too large", Status: http.StatusRequestEntityTooLarge, Code: "request.body_too_large"}, status: http.StatusRequestEntityTooLarge, code: "request.body_too_large", detail: "too large"},
+		{name: "endUserPlain", err: web.EndUserError{Message: "plain", Status: http.StatusTooEarly}, status: http.StatusTooEarly, detail: "plain"},
+		{name: "registered", err: errCustom, status: http.StatusConflict, code: "test.custom"},
+		{name: "validation", err: fmt.Errorf("validation: %w", validate.FieldErrors{{Field: "email", Err: "email is required"}}), status: http.StatusBadRequest, code: "validation.failed"},
+		{name: "external", err: web.ExternalError{Err: errors.New("down")}, status: http.StatusServiceUnavailable, code: "service.unavailable"},
+		{name: "internal", err: errors.New("database password is hunter2"), status: http.StatusInternalServerError, code: "internal", detail: "something went really wrong"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := problems.From(context.Background(), tt.err)
+			if p.Status != tt.status {
+				t.Errorf("status want %d got %d", tt.status, p.Status)
+			}
+			if p.Code != tt.code {
+				t.Errorf("code want %q got %q", tt.code, p.Code)
+			}
+			if tt.detail != "" && p.Detail != tt.detail {
+				t.Errorf("detail want %q got %q", tt.detail, p.Detail)
+			}
+			if tt.code != "" && p.Type != "urn:wishlist:problem:"+tt.code {
+				t.Errorf("type should derive from the code, got %q", p.Type)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic when registering an error twice")
+		}
+	}()
+	problems.Register(errCustom, problems.Definition{Code: "test.again"})
+}
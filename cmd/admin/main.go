@@ -1,16 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/caarlos0/env/v10"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/so-heil/wishlist/business/database/db"
 	"github.com/so-heil/wishlist/business/database/migration"
+	"github.com/so-heil/wishlist/business/validate"
+	v1 "github.com/so-heil/wishlist/cmd/wishapi/v1"
+	"github.com/so-heil/wishlist/foundation/web"
 	"go.uber.org/zap"
 )
 
@@ -50,6 +57,10 @@ func run() error {
 	switch args[0] {
 	case "migrate":
 		return startMigration(args, l)
+	case "breached":
+		return buildBreachedFilter(args, l)
+	case "openapi":
+		return writeOpenAPI(args, l)
 	default:
 		return fmt.Errorf("admin: command %s not supported", args[0])
 	}
@@ -127,3 +138,108 @@ func migrateUp(mgrt *migration.Migration, l *zap.SugaredLogger) error {
 	l.Infow("migration state", "version", v, "dirty", d)
 	return nil
 }
+
+// buildBreachedFilter builds the bloom filter read by the password rule of the validator
+// from a file of breached passwords, one per line: breached <passwords> <output> [fp-rate]
+func buildBreachedFilter(args []string, l *zap.SugaredLogger) error {
+	args = args[1:]
+	if len(args) < 2 {
+		return errors.New("breached: passwords and output files are required")
+	}
+
+	fpRate := 0.001
+	if len(args) > 2 {
+		var err error
+		if fpRate, err = strconv.ParseFloat(args[2], 64); err != nil {
+			return fmt.Errorf("breached: parse false positive rate: %w", err)
+		}
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("breached: open passwords: %w", err)
+	}
+	defer in.Close()
+
+	// Count passwords first to size the filter
+	var n int
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		if sc.Text() != "" {
+			n++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("breached: read passwords: %w", err)
+	}
+
+	filter, err := validate.NewBloomFilter(n, fpRate)
+	if err != nil {
+		return fmt.Errorf("breached: %w", err)
+	}
+
+	if _, err := in.Seek(0, 0); err != nil {
+		return fmt.Errorf("breached: rewind passwords: %w", err)
+	}
+	sc = bufio.NewScanner(in)
+	for sc.Scan() {
+		if sc.Text() != "" {
+			filter.Add(sc.Text())
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("breached: read passwords: %w", err)
+	}
+
+	out, err := os.Create(args[1])
+	if err != nil {
+		return fmt.Errorf("breached: create output: %w", err)
+	}
+	size, err := filter.WriteTo(out)
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("breached: write filter: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("breached: close output: %w", err)
+	}
+
+	l.Infow("breached passwords filter built", "passwords", n, "fpRate", fpRate, "bytes", size)
+	return nil
+}
+
+// writeOpenAPI writes the OpenAPI document of wishapi to the file given, or stdout when it is
+// "-", the output is stable so changes to the API show up in the diff of a review
+func writeOpenAPI(args []string, l *zap.SugaredLogger) error {
+	args = args[1:]
+	if err := checkArgs(args); err != nil {
+		return errors.New("openapi: output file is required")
+	}
+
+	// Routes are registered on an app that never serves, its handler groups need no dependencies
+	if err := validate.Init(validate.Config{}); err != nil {
+		return fmt.Errorf("openapi: init validator: %w", err)
+	}
+	app := web.NewApp(l, http.NewServeMux(), nil, make(chan os.Signal, 1), nil)
+	docs, err := v1.Routes(v1.Config{App: app, Log: l})
+	if err != nil {
+		return fmt.Errorf("openapi: register routes: %w", err)
+	}
+
+	jsn, err := json.MarshalIndent(docs.Spec(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("openapi: marshal document: %w", err)
+	}
+	jsn = append(jsn, '\n')
+
+	if args[0] == "-" {
+		_, err := os.Stdout.Write(jsn)
+		return err
+	}
+	if err := os.WriteFile(args[0], jsn, 0o644); err != nil {
+		return fmt.Errorf("openapi: write document: %w", err)
+	}
+
+	l.Infow("openapi document written", "path", args[0])
+	return nil
+}
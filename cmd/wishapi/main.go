@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,17 +18,21 @@ import (
 	"github.com/so-heil/wishlist/business/database/db"
 	"github.com/so-heil/wishlist/business/email"
 	"github.com/so-heil/wishlist/business/keystore"
+	"github.com/so-heil/wishlist/business/metrics"
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
 	"github.com/so-heil/wishlist/business/validate"
+	"github.com/so-heil/wishlist/business/web/debug"
 	"github.com/so-heil/wishlist/business/web/middlewares"
+	v1 "github.com/so-heil/wishlist/cmd/wishapi/v1"
 	"github.com/so-heil/wishlist/cmd/wishapi/v1/handlers/probes"
 	"github.com/so-heil/wishlist/cmd/wishapi/v1/handlers/usergrp"
+	"github.com/so-heil/wishlist/foundation/health"
+	"github.com/so-heil/wishlist/foundation/lifecycle"
+	"github.com/so-heil/wishlist/foundation/tracing"
 	"github.com/so-heil/wishlist/foundation/web"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/zipkin"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.9.0"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.uber.org/zap"
 )
 
@@ -37,16 +44,32 @@ func main() {
 
 type config struct {
 	Web struct {
-		Address         string        `env:"ADDRESS" envDefault:"0.0.0.0:3000"`
-		ReadTimeout     time.Duration `env:"READ_TIMEOUT" envDefault:"5s"`
-		WriteTimeout    time.Duration `env:"WRITE_TIMEOUT" envDefault:"10s"`
-		IdleTimeout     time.Duration `env:"IDLE_TIMEOUT" envDefault:"120s"`
-		ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
+		Address                  string        `env:"ADDRESS" envDefault:"0.0.0.0:3000"`
+		ReadTimeout              time.Duration `env:"READ_TIMEOUT" envDefault:"5s"`
+		WriteTimeout             time.Duration `env:"WRITE_TIMEOUT" envDefault:"10s"`
+		IdleTimeout              time.Duration `env:"IDLE_TIMEOUT" envDefault:"120s"`
+		ShutdownTimeout          time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
+		ComponentShutdownTimeout time.Duration `env:"COMPONENT_SHUTDOWN_TIMEOUT" envDefault:"5s"`
+		LogSampleRate            float64       `env:"LOG_SUCCESS_SAMPLE_RATE" envDefault:"1"`
+		MaxBodySize              int64         `env:"MAX_BODY_SIZE" envDefault:"1048576"`
+		CompressionThreshold     int           `env:"COMPRESSION_THRESHOLD" envDefault:"1024"`
+		CORS                     struct {
+			AllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" envSeparator:","`
+			AllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" envSeparator:"," envDefault:"GET,POST,PUT,PATCH,DELETE"`
+			AllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" envSeparator:"," envDefault:"Authorization,Content-Type,Idempotency-Key"`
+			ExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS" envSeparator:"," envDefault:"API-Version,Deprecation,Sunset,Link,Idempotent-Replayed"`
+			AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" envDefault:"false"`
+			MaxAge           time.Duration `env:"CORS_MAX_AGE" envDefault:"10m"`
+			// RouteOrigins overrides the allowed origins by route pattern, e.g.
+			// /v1/users/login=https://app.example.com,https://admin.example.com;/docs=*
+			RouteOrigins map[string]string `env:"CORS_ROUTE_ORIGINS" envSeparator:";" envKeyValSeparator:"="`
+		}
 	}
 	App struct {
 		Users struct {
 			OTPLength                int           `env:"OTP_LENGTH" envDefault:"6"`
 			OTPTimeout               time.Duration `env:"OTP_TIMEOUT" envDefault:"90s"`
+			OTPCooldown              time.Duration `env:"OTP_COOLDOWN" envDefault:"60s"`
 			OTPTemplate              string        `env:"OTP_TEMPLATE" envDefault:"Your email verification code is {{.}}."`
 			EmailVerifiedExpiration  time.Duration `env:"EMAIL_VERIFIED_EXPIRATION" envDefault:"30m"`
 			UserSessionExpiration    time.Duration `env:"USER_SESSION_EXPIRATION" envDefault:"36h"`
@@ -54,9 +77,32 @@ type config struct {
 			SendMailContextTimeout   time.Duration `env:"SEND_MAIL_CONTEXT_TIMEOUT" envDefault:"10s"`
 			CourierAPIKey            string        `env:"COURIER_API_KEY"`
 		}
-		CacheSize           int           `env:"CACHE_SIZE" envDefault:"100000"`
-		KeyRotationPeriod   time.Duration `env:"KEY_ROTATION_PERIOD" envDefault:"24h"`
-		KeyExpirationPeriod time.Duration `env:"KEY_EXPIRATION_PERIOD" envDefault:"48h"`
+		Validation struct {
+			PasswordMinLength      int      `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`
+			PasswordRequireUpper   bool     `env:"PASSWORD_REQUIRE_UPPER" envDefault:"true"`
+			PasswordRequireLower   bool     `env:"PASSWORD_REQUIRE_LOWER" envDefault:"false"`
+			PasswordRequireNumber  bool     `env:"PASSWORD_REQUIRE_NUMBER" envDefault:"true"`
+			PasswordRequireSymbol  bool     `env:"PASSWORD_REQUIRE_SYMBOL" envDefault:"false"`
+			PasswordBreachedFilter string   `env:"PASSWORD_BREACHED_FILTER"`
+			ReservedUsernames      []string `env:"RESERVED_USERNAMES" envSeparator:","`
+		}
+		Idempotency struct {
+			TTL     time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+			LockTTL time.Duration `env:"IDEMPOTENCY_LOCK_TTL" envDefault:"1m"`
+		}
+		CacheSize              int           `env:"CACHE_SIZE" envDefault:"67108864"`
+		KeyRotationPeriod      time.Duration `env:"KEY_ROTATION_PERIOD" envDefault:"24h"`
+		KeyExpirationPeriod    time.Duration `env:"KEY_EXPIRATION_PERIOD" envDefault:"48h"`
+		KeyRotationMaxFailures int           `env:"KEY_ROTATION_MAX_FAILURES" envDefault:"2"`
+	}
+	KeyValue struct {
+		Store         string        `env:"KV_STORE" envDefault:"freecache"`
+		RedisAddress  string        `env:"REDIS_ADDRESS" envDefault:"redis-svc:6379"`
+		RedisPassword string        `env:"REDIS_PASSWORD"`
+		RedisDB       int           `env:"REDIS_DB" envDefault:"0"`
+		RedisPoolSize int           `env:"REDIS_POOL_SIZE" envDefault:"10"`
+		SweepInterval time.Duration `env:"KV_SWEEP_INTERVAL" envDefault:"1m"`
+		SweepBatch    int           `env:"KV_SWEEP_BATCH" envDefault:"1000"`
 	}
 	DB struct {
 		User       string `env:"DB_USER" envDefault:"postgres"`
@@ -67,8 +113,17 @@ type config struct {
 		DisableTLS bool   `env:"DB_DISABLE_TLS" envDefault:"true"`
 	}
 	Debug struct {
-		CollectorURL string  `env:"COLLECTOR_URL" envDefault:"http://zipkin.observe.svc.cluster.local:9411/api/v2/spans"`
-		Probibility  float64 `env:"PROBIBILITY" envDefault:"1"`
+		Address       string        `env:"DEBUG_ADDRESS" envDefault:"0.0.0.0:4000"`
+		TraceExporter string        `env:"TRACE_EXPORTER" envDefault:"zipkin"`
+		CollectorURL  string        `env:"COLLECTOR_URL" envDefault:"http://zipkin.observe.svc.cluster.local:9411/api/v2/spans"`
+		Probibility   float64       `env:"PROBIBILITY" envDefault:"1"`
+		CheckTimeout  time.Duration `env:"HEALTH_CHECK_TIMEOUT" envDefault:"2s"`
+	}
+	K8s struct {
+		Namespace string `env:"KUBERNETES_NAMESPACE"`
+		Name      string `env:"KUBERNETES_NAME"`
+		PodIP     string `env:"KUBERNETES_POD_IP"`
+		Node      string `env:"KUBERNETES_NODE_NAME"`
 	}
 	Mail struct {
 		Password string `env:"GMAIL_PASSWORD"`
@@ -95,23 +150,32 @@ func run() error {
 	// *** Init graceful shutdown ***
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT)
-	serverErr := make(chan error, 1)
+	serverErr := make(chan error, 2)
+	keystoreErr := make(chan error, 1)
+
+	// Components are stopped in reverse start order, so the servers stop before what they use
+	lc := lifecycle.New(l, cfg.Web.ComponentShutdownTimeout)
+	defer func() {
+		l.Infoln("shutdown: stopping components")
+		if err := lc.Shutdown(context.Background()); err != nil {
+			l.Errorw("shutdown: components", "ERROR", err)
+		}
+		l.Infoln("shutdown: shutdown completed")
+	}()
 
 	// *** Start tracer ***
-	l.Infoln("startup: starting tracer")
-	traceProvider, terr := startTracing(
-		"wishapi",
-		cfg.Debug.CollectorURL,
-		cfg.Debug.Probibility,
-	)
+	l.Infow("startup: starting tracer", "exporter", cfg.Debug.TraceExporter)
+	traceProvider, terr := tracing.Start(context.Background(), tracing.Config{
+		ServiceName: "wishapi",
+		Exporter:    cfg.Debug.TraceExporter,
+		Endpoint:    cfg.Debug.CollectorURL,
+		Probability: cfg.Debug.Probibility,
+		Attributes:  resourceAttributes(cfg),
+	})
 	if terr != nil {
 		return fmt.Errorf("start tracer: %w", terr)
 	}
-	defer func(traceProvider *trace.TracerProvider, ctx context.Context) {
-		if terr := traceProvider.Shutdown(ctx); terr != nil {
-			fmt.Printf("trace shutdown: %s", terr)
-		}
-	}(traceProvider, context.Background())
+	lc.Add("tracer", 0, traceProvider.Shutdown)
 	tracer := traceProvider.Tracer("webserver")
 
 	// *** Init database connection ***
@@ -129,51 +193,149 @@ func run() error {
 	if cerr != nil {
 		return fmt.Errorf("open database connection: %w", cerr)
 	}
+	lc.AddCloser("database", 0, database)
+	if err := metrics.RegisterDB(database.DB.DB, cfg.DB.Name); err != nil {
+		return fmt.Errorf("register database metrics: %w", err)
+	}
+
+	// *** Init keyvalue store ***
+	l.Infow("startup: initializing keyvalue store", "store", cfg.KeyValue.Store)
+	kv, err := openKeyValue(cfg, database, l)
+	if err != nil {
+		return fmt.Errorf("open keyvalue store: %w", err)
+	}
+	if closer, ok := kv.(io.Closer); ok {
+		lc.AddCloser("keyvalue store", 0, closer)
+	}
+	// freecache fails entries beyond 1/1024 of its size, which would keep responses from being replayed
+	if fc, ok := kv.(*kvstores.FreeCache); ok && fc.MaxEntrySize() < middlewares.IdempotencyMinEntrySize {
+		return fmt.Errorf("CACHE_SIZE %d takes entries up to %d bytes, idempotent responses need %d", cfg.App.CacheSize, fc.MaxEntrySize(), middlewares.IdempotencyMinEntrySize)
+	}
 
 	// *** Init keystore and auth ***
 	l.Infoln("startup: initializing keystore and auth")
-	ks, err := keystore.New(cfg.App.KeyRotationPeriod, cfg.App.KeyExpirationPeriod, shutdown, l)
+	ks, err := keystore.New(
+		cfg.App.KeyRotationPeriod,
+		cfg.App.KeyExpirationPeriod,
+		l,
+		keystore.WithErrorHandler(keystoreErrors(l, cfg.App.KeyRotationMaxFailures, keystoreErr)),
+	)
 	if err != nil {
 		return fmt.Errorf("init keystore: %w", err)
 	}
+	lc.Go("keystore rotation", 0, ks.Rotate)
 	a := auth.New(ks)
 
 	// *** Init web.App ***
+	logConfig := middlewares.LogConfig{SuccessSampleRate: cfg.Web.LogSampleRate}
+	cors, err := middlewares.CORS(corsConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("init cors: %w", err)
+	}
 	l.Infoln("startup: init web app")
 	app := web.NewApp(
 		l,
 		http.NewServeMux(),
-		[]web.Middleware{middlewares.Log(l), middlewares.Errors(l)},
+		[]web.Middleware{
+			middlewares.RequestID(),
+			middlewares.Locale(),
+			middlewares.Log(l, logConfig),
+			middlewares.Metrics(),
+			middlewares.Errors(l),
+			middlewares.Panics(),
+			middlewares.BodyLimit(cfg.Web.MaxBodySize),
+			cors,
+		},
 		shutdown,
 		tracer,
+		web.WithCompressionThreshold(cfg.Web.CompressionThreshold),
+		web.WithDefaultVersion(v1.Version),
+		web.WithVendor("wishlist"),
 	)
 
 	// *** Init validator ***
-	if err := validate.Init(); err != nil {
+	if err := validate.Init(validate.Config{
+		Password: validate.PasswordPolicy{
+			MinLength:      cfg.App.Validation.PasswordMinLength,
+			RequireUpper:   cfg.App.Validation.PasswordRequireUpper,
+			RequireLower:   cfg.App.Validation.PasswordRequireLower,
+			RequireNumber:  cfg.App.Validation.PasswordRequireNumber,
+			RequireSymbol:  cfg.App.Validation.PasswordRequireSymbol,
+			BreachedFilter: cfg.App.Validation.PasswordBreachedFilter,
+		},
+		ReservedUsernames: cfg.App.Validation.ReservedUsernames,
+	}); err != nil {
 		return fmt.Errorf("init validator: %w", err)
 	}
 
 	// *** Build handler groups and register routes to app ***
-	emailClient := email.NewCourierClient(cfg.App.Users.CourierAPIKey)
-	userGroup, err := usergrp.New(usergrp.Config{
-		EmailVerifyExp:           cfg.App.Users.EmailVerifiedExpiration,
-		UserSessExp:              cfg.App.Users.UserSessionExpiration,
-		MailTimeout:              cfg.App.Users.SendMailContextTimeout,
-		EmailVerificationSubject: cfg.App.Users.EmailVerificationSubject,
-		CacheSize:                cfg.App.CacheSize,
-		OTPLength:                cfg.App.Users.OTPLength,
-		OTPTimeout:               cfg.App.Users.OTPTimeout,
-	}, emailClient, app, a, database, l, cfg.App.Users.OTPTemplate)
-	if err != nil {
-		return fmt.Errorf("create usergroup: %w", err)
+	emailClient := email.NewInstrumentedClient(email.NewCourierClient(cfg.App.Users.CourierAPIKey))
+	if _, err := v1.Routes(v1.Config{
+		App:   app,
+		Log:   l,
+		Auth:  a,
+		DB:    database,
+		KV:    kv,
+		Email: emailClient,
+		Users: usergrp.Config{
+			EmailVerifyExp:           cfg.App.Users.EmailVerifiedExpiration,
+			UserSessExp:              cfg.App.Users.UserSessionExpiration,
+			MailTimeout:              cfg.App.Users.SendMailContextTimeout,
+			EmailVerificationSubject: cfg.App.Users.EmailVerificationSubject,
+			OTPLength:                cfg.App.Users.OTPLength,
+			OTPTimeout:               cfg.App.Users.OTPTimeout,
+			OTPCooldown:              cfg.App.Users.OTPCooldown,
+		},
+		OTPTemplate: cfg.App.Users.OTPTemplate,
+		Idempotency: middlewares.IdempotencyConfig{
+			TTL:     cfg.App.Idempotency.TTL,
+			LockTTL: cfg.App.Idempotency.LockTTL,
+		},
+	}); err != nil {
+		return fmt.Errorf("register routes: %w", err)
 	}
 
+	// *** Init debug app, it serves probes, pprof, expvar and metrics apart from the public app ***
+	debugApp := web.NewApp(
+		l,
+		debug.Mux(),
+		[]web.Middleware{
+			middlewares.RequestID(),
+			middlewares.Log(l, logConfig),
+			middlewares.Errors(l),
+			middlewares.Panics(),
+		},
+		shutdown,
+		nil,
+	)
+	// *** Register readiness checks ***
+	checks := health.NewRegistry()
+	checks.RegisterChecker("database", cfg.Debug.CheckTimeout, true, database)
+	if checker, ok := kv.(health.Checker); ok {
+		checks.RegisterChecker("keyvalue", cfg.Debug.CheckTimeout, true, checker)
+	}
+	checks.RegisterChecker("keystore", cfg.Debug.CheckTimeout, true, ks)
+
 	handlerGroups{
-		"debug": probes.New(l, app),
-		"users": userGroup,
+		"debug": probes.New(l, debugApp, checks),
 	}.handleAll()
 
-	// *** Start server ***
+	// *** Start servers ***
+	// The debug server starts first so it stops last, it keeps answering probes and metrics
+	// while the public server drains. No write timeout on the debug server, profiles and
+	// traces stream for as long as requested.
+	debugSrv := http.Server{
+		Addr:        cfg.Debug.Address,
+		Handler:     debugApp,
+		ReadTimeout: cfg.Web.ReadTimeout,
+		IdleTimeout: cfg.Web.IdleTimeout,
+	}
+	go func() {
+		l.Infow("startup: starting debug server", "address", cfg.Debug.Address)
+		serverErr <- fmt.Errorf("debug server: %w", debugSrv.ListenAndServe())
+	}()
+	lc.Add("debug server", cfg.Web.ShutdownTimeout, stopServer(&debugSrv))
+
 	srv := http.Server{
 		Addr:         cfg.Web.Address,
 		Handler:      app,
@@ -184,27 +346,59 @@ func run() error {
 	}
 	go func() {
 		l.Infow("startup: starting wishapi web service", "address", cfg.Web.Address)
-		serverErr <- srv.ListenAndServe()
+		serverErr <- fmt.Errorf("web server: %w", srv.ListenAndServe())
 	}()
+	lc.Add("web server", cfg.Web.ShutdownTimeout, stopServer(&srv))
 
 	// *** Listen for shutdown signal ***
 	select {
 	case err := <-serverErr:
-		return fmt.Errorf("web server: %w", err)
-	case <-shutdown:
-		l.Infoln("shutdown: starting graceful shutdown")
-		defer l.Infoln("shutdown: shutdown completed")
+		return err
+	case err := <-lc.Failed():
+		return err
+	case err := <-keystoreErr:
+		return fmt.Errorf("keystore: %w", err)
+	case sig := <-shutdown:
+		l.Infow("shutdown: starting graceful shutdown", "signal", sig)
+	}
+
+	return nil
+}
+
+// keystoreErrors logs and counts the critical errors of the keystore and escalates them to
+// shutdown. Rotations are retried until maxFailures of them failed in a row, the active key
+// is still valid meanwhile, any other error means the keystore lost its integrity.
+func keystoreErrors(l *zap.SugaredLogger, maxFailures int, escalate chan<- error) keystore.ErrorHandler {
+	return func(err error) {
+		kind := "integrity"
+		var re *keystore.RotationError
+		rotation := errors.As(err, &re)
+		if rotation {
+			kind = "rotation"
+		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
-		defer cancel()
+		metrics.KeyStoreError(kind)
+		l.Errorw("keystore: critical error", "kind", kind, "ERROR", err)
 
+		if rotation && re.Consecutive < maxFailures {
+			return
+		}
+		select {
+		case escalate <- err:
+		default:
+		}
+	}
+}
+
+// stopServer drains the server, it is closed when it does not drain in time
+func stopServer(srv *http.Server) lifecycle.StopFunc {
+	return func(ctx context.Context) error {
 		if err := srv.Shutdown(ctx); err != nil {
 			srv.Close()
-			return fmt.Errorf("shutdown: %w", err)
+			return err
 		}
+		return nil
 	}
-
-	return nil
 }
 
 type handlerGroup interface {
@@ -219,27 +413,67 @@ func (g handlerGroups) handleAll() {
 	}
 }
 
-func startTracing(serviceName, collectURL string, probability float64) (*trace.TracerProvider, error) {
-	exporter, err := zipkin.New(collectURL)
-	if err != nil {
-		return nil, fmt.Errorf("create trace exporter: %w", err)
+func openKeyValue(cfg config, database *db.DB, l *zap.SugaredLogger) (keyvalue.KeyValueStore, error) {
+	switch cfg.KeyValue.Store {
+	case "freecache":
+		return kvstores.NewFreeCache(cfg.App.CacheSize), nil
+	case "redis":
+		return kvstores.NewRedis(kvstores.RedisConfig{
+			Address:  cfg.KeyValue.RedisAddress,
+			Password: cfg.KeyValue.RedisPassword,
+			DB:       cfg.KeyValue.RedisDB,
+			PoolSize: cfg.KeyValue.RedisPoolSize,
+		})
+	case "postgres":
+		return kvstores.NewPostgres(database, kvstores.PostgresConfig{
+			SweepInterval: cfg.KeyValue.SweepInterval,
+			SweepBatch:    cfg.KeyValue.SweepBatch,
+		}, l), nil
+	default:
+		return nil, fmt.Errorf("keyvalue store %q is not supported", cfg.KeyValue.Store)
 	}
+}
 
-	traceProvider := trace.NewTracerProvider(
-		trace.WithSampler(trace.TraceIDRatioBased(probability)),
-		trace.WithBatcher(exporter, trace.WithBatchTimeout(time.Second)),
-		trace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String(serviceName),
-			)),
-	)
-	otel.SetTracerProvider(traceProvider)
+// corsConfig builds the CORS policies, routes with their own origins share the rest of
+// the default policy
+func corsConfig(cfg config) middlewares.CORSConfig {
+	def := middlewares.CORSPolicy{
+		AllowedOrigins:   cfg.Web.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.Web.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.Web.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.Web.CORS.ExposedHeaders,
+		AllowCredentials: cfg.Web.CORS.AllowCredentials,
+		MaxAge:           cfg.Web.CORS.MaxAge,
+	}
+
+	routes := make(map[string]middlewares.CORSPolicy, len(cfg.Web.CORS.RouteOrigins))
+	for route, origins := range cfg.Web.CORS.RouteOrigins {
+		p := def
+		p.AllowedOrigins = strings.Split(origins, ",")
+		routes[route] = p
+	}
 
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	return middlewares.CORSConfig{Default: def, Routes: routes}
+}
+
+// resourceAttributes describes where the service runs for the spans it exports
+func resourceAttributes(cfg config) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, semconv.HostName(host))
+	}
+
+	k8s := []attribute.KeyValue{
+		semconv.K8SNamespaceName(cfg.K8s.Namespace),
+		semconv.K8SPodName(cfg.K8s.Name),
+		semconv.K8SNodeName(cfg.K8s.Node),
+		attribute.String("k8s.pod.ip", cfg.K8s.PodIP),
+	}
+	for _, attr := range k8s {
+		if attr.Value.AsString() != "" {
+			attrs = append(attrs, attr)
+		}
+	}
 
-	return traceProvider, nil
+	return attrs
 }
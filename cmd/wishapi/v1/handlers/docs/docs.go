@@ -0,0 +1,51 @@
+// Package docs serves the OpenAPI document of the routes registered on the app
+package docs
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/so-heil/wishlist/business/validate"
+	"github.com/so-heil/wishlist/foundation/openapi"
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+// Info describes the API in its OpenAPI document
+var Info = openapi.Info{
+	Title:       "Wishlist API",
+	Version:     "1.0.0",
+	Description: "Users define wishlists and add products to them from different sources and online-shops.",
+}
+
+type Docs struct {
+	app *web.App
+
+	once sync.Once
+	spec *openapi.Document
+}
+
+func New(app *web.App) *Docs {
+	return &Docs{app: app}
+}
+
+// Spec generates the document on first use, every route has to be registered by then
+func (d *Docs) Spec() *openapi.Document {
+	d.once.Do(func() {
+		g := openapi.NewGenerator()
+		validate.RegisterOpenAPITags(g)
+		d.spec = g.Generate(Info, d.app.Routes())
+	})
+	return d.spec
+}
+
+func (d *Docs) openAPI(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(w, ctx, d.Spec(), http.StatusOK)
+}
+
+func (d *Docs) Routes(group string) {
+	d.app.Handle(http.MethodGet, group, "/openapi.json", d.openAPI, web.RouteDoc{
+		Summary: "OpenAPI document of the API",
+		Tags:    []string{"docs"},
+	})
+}
@@ -0,0 +1,50 @@
+package docs_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/so-heil/wishlist/cmd/wishapi/v1/handlers/docs"
+	"github.com/so-heil/wishlist/foundation/openapi"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.uber.org/zap"
+)
+
+func TestOpenAPI(t *testing.T) {
+	app := web.NewApp(zap.NewNop().Sugar(), http.NewServeMux(), nil, make(chan os.Signal, 1), nil)
+	app.Handle(http.MethodGet, "items", "/{id}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}, web.RouteDoc{Summary: "Get an item"})
+
+	docs.New(app).Routes("")
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("should be able to get the document: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status want %d got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode document: %s", err)
+	}
+	if doc.Info.Title != docs.Info.Title {
+		t.Errorf("title want %q got %q", docs.Info.Title, doc.Info.Title)
+	}
+	if op := doc.Paths["/items/{id}"]["get"]; op == nil || op.Summary != "Get an item" {
+		t.Errorf("should describe routes registered before, got %+v", doc.Paths)
+	}
+	if doc.Paths["/openapi.json"]["get"] == nil {
+		t.Error("should describe itself")
+	}
+}
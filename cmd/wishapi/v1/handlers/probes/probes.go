@@ -5,38 +5,53 @@ import (
 	"net/http"
 	"os"
 	"runtime"
-	"time"
 
+	"github.com/so-heil/wishlist/foundation/health"
 	"github.com/so-heil/wishlist/foundation/web"
 	"go.uber.org/zap"
 )
 
+// Probes answers the kubernetes probes, its routes belong on the debug listener
 type Probes struct {
-	log *zap.SugaredLogger
-	app *web.App
+	log    *zap.SugaredLogger
+	app    *web.App
+	checks *health.Registry
 }
 
-func New(log *zap.SugaredLogger, app *web.App) *Probes {
-	return &Probes{log: log, app: app}
+func New(log *zap.SugaredLogger, app *web.App, checks *health.Registry) *Probes {
+	return &Probes{log: log, app: app, checks: checks}
 }
 
+// readiness runs the registered dependency checks, the service is unavailable while
+// any critical dependency fails
 func (p *Probes) readiness(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
+	report := p.checks.Run(ctx)
 
 	status := "ok"
 	statusCode := http.StatusOK
+	if !report.Ready {
+		status = "unavailable"
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	for _, res := range report.Results {
+		if res.Error != "" {
+			p.log.Errorw("readiness: check failed", "check", res.Name, "critical", res.Critical, "ERROR", res.Error)
+		}
+	}
 
 	data := struct {
-		Status string `json:"status"`
+		Status string          `json:"status"`
+		Checks []health.Result `json:"checks"`
 	}{
 		Status: status,
+		Checks: report.Results,
 	}
 
 	return web.Respond(w, ctx, data, statusCode)
 }
 
-// TODO: this stuff should not be accessible publicly
+// liveness does not depend on any check so failing dependencies do not get the pod restarted
 func (p *Probes) liveness(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
 	host, err := os.Hostname()
 	if err != nil {
@@ -1,15 +1,19 @@
 package usergrp
 
-import "github.com/so-heil/wishlist/business/validate"
+import (
+	"context"
+
+	"github.com/so-heil/wishlist/business/validate"
+)
 
 type APINewUser struct {
 	Name     string `json:"name" validate:"required"`
-	Username string `json:"username" validate:"required,lowercase,alphanum,max=20"`
+	Username string `json:"username" validate:"required,username"`
 	Password string `json:"password" validate:"required,password"`
 }
 
-func (anu *APINewUser) Validate() error {
-	return validate.Check(anu)
+func (anu *APINewUser) Validate(ctx context.Context) error {
+	return validate.Check(ctx, anu)
 }
 
 type APIUserAuthentication struct {
@@ -17,16 +21,16 @@ type APIUserAuthentication struct {
 	Password string `json:"password" validate:"required,min=8,max=32"`
 }
 
-func (aua *APIUserAuthentication) Validate() error {
-	return validate.Check(aua)
+func (aua *APIUserAuthentication) Validate(ctx context.Context) error {
+	return validate.Check(ctx, aua)
 }
 
 type APIEmailVerification struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
-func (aev *APIEmailVerification) Validate() error {
-	return validate.Check(aev)
+func (aev *APIEmailVerification) Validate(ctx context.Context) error {
+	return validate.Check(ctx, aev)
 }
 
 type APIOTPVerfication struct {
@@ -34,8 +38,13 @@ type APIOTPVerfication struct {
 	OTP   string `json:"otp" validate:"required,len=6,numeric"`
 }
 
-func (aov *APIOTPVerfication) Validate() error {
-	return validate.Check(aov)
+func (aov *APIOTPVerfication) Validate(ctx context.Context) error {
+	return validate.Check(ctx, aov)
+}
+
+type emailVerification struct {
+	ExpiresIn   int `json:"expires_in"`
+	ResendAfter int `json:"resend_after"`
 }
 
 type token struct {
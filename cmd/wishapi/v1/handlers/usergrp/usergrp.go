@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"text/template"
 	"time"
 
@@ -13,25 +14,31 @@ import (
 	"github.com/so-heil/wishlist/business/email"
 	"github.com/so-heil/wishlist/business/entities/user"
 	"github.com/so-heil/wishlist/business/otp"
-	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
 	"github.com/so-heil/wishlist/business/storage/postgres/userdb"
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/business/web/problems"
 	"github.com/so-heil/wishlist/foundation/web"
 	"go.uber.org/zap"
 )
 
+// otpNamespace keeps verification codes apart from other keys of the shared store
+const otpNamespace = "otp"
+
 type Config struct {
 	EmailVerifyExp           time.Duration
 	UserSessExp              time.Duration
 	MailTimeout              time.Duration
 	EmailVerificationSubject string
-	CacheSize                int
 	OTPLength                int
 	OTPTimeout               time.Duration
+	OTPCooldown              time.Duration
 }
 
 type UserGroup struct {
 	bookKeeper  *user.BookKeeper
-	app         *web.App
+	router      web.Router
+	idempotent  web.Middleware
 	otpClient   *otp.OTP
 	a           *auth.Auth
 	emailClient email.Client
@@ -41,9 +48,11 @@ type UserGroup struct {
 func New(
 	cfg Config,
 	emailClient email.Client,
-	app *web.App,
+	router web.Router,
+	idempotent web.Middleware,
 	a *auth.Auth,
 	dbase *db.DB,
+	kv keyvalue.KeyValueStore,
 	l *zap.SugaredLogger,
 	otpTemplate string,
 ) (*UserGroup, error) {
@@ -53,15 +62,17 @@ func New(
 	}
 
 	otpClient := otp.New(
-		kvstores.NewFreeCache(cfg.CacheSize),
+		keyvalue.Namespace(kv, otpNamespace),
 		cfg.OTPLength,
 		cfg.OTPTimeout,
+		cfg.OTPCooldown,
 		otpTempl,
 	)
 
 	return &UserGroup{
 		bookKeeper:  user.NewBookKeeper(userdb.New(dbase, l)),
-		app:         app,
+		router:      router,
+		idempotent:  idempotent,
 		otpClient:   otpClient,
 		a:           a,
 		emailClient: emailClient,
@@ -71,7 +82,7 @@ func New(
 
 func (ug *UserGroup) verifyEmail(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	var aev APIEmailVerification
-	if err := web.DecodeBody(r.Body, &aev); err != nil {
+	if err := web.DecodeBody(ctx, r, &aev); err != nil {
 		return err
 	}
 
@@ -83,25 +94,22 @@ func (ug *UserGroup) verifyEmail(ctx context.Context, w http.ResponseWriter, r *
 		return web.EUEFromError(user.ErrUniqueEmail, http.StatusBadRequest)
 	}
 
-	exists, err := ug.otpClient.Exists(aev.Email)
+	issued, err := ug.otpClient.Create(aev.Email)
 	if err != nil {
-		return fmt.Errorf("check code exists: %w", err)
-	}
-
-	if exists {
-		return web.EndUserError{
-			Message: user.ErrEmailVerifySoon.Error(),
-			Status:  http.StatusTooEarly,
+		if errors.Is(err, otp.ErrCodeExists) {
+			retryAfter, err := ug.otpClient.Cooldown(aev.Email)
+			if err != nil {
+				return fmt.Errorf("otp cooldown: %w", err)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
+			return web.EUEFromError(user.ErrEmailVerifySoon, http.StatusTooEarly)
 		}
+		return fmt.Errorf("create otp code: %w", err)
 	}
 
-	code, err := ug.otpClient.GenCode()
-	if err != nil {
-		return fmt.Errorf("generate otp code: %w", err)
-	}
-
-	message, err := ug.otpClient.Message(code)
+	message, err := ug.otpClient.Message(issued.Code)
 	if err != nil {
+		ug.otpClient.Revoke(aev.Email)
 		return fmt.Errorf("message for otp: %w", err)
 	}
 
@@ -113,21 +121,22 @@ func (ug *UserGroup) verifyEmail(ctx context.Context, w http.ResponseWriter, r *
 		Subject: ug.cfg.EmailVerificationSubject,
 		To:      aev.Email,
 	}); err != nil {
+		// Let the user ask for another code right away as this one never reached them
+		ug.otpClient.Revoke(aev.Email)
 		return web.ExternalError{
 			Err: fmt.Errorf("send email verification mail: %w", err),
 		}
 	}
 
-	if err := ug.otpClient.Save(aev.Email, code); err != nil {
-		return fmt.Errorf("save otp code: %w", err)
-	}
-
-	return web.Respond(w, ctx, nil, http.StatusNoContent)
+	return web.Respond(w, ctx, emailVerification{
+		ExpiresIn:   seconds(issued.ExpiresIn),
+		ResendAfter: seconds(issued.ResendAfter),
+	}, http.StatusOK)
 }
 
 func (ug *UserGroup) verifyOTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	var aov APIOTPVerfication
-	if err := web.DecodeBody(r.Body, &aov); err != nil {
+	if err := web.DecodeBody(ctx, r, &aov); err != nil {
 		return err
 	}
 
@@ -145,7 +154,7 @@ func (ug *UserGroup) verifyOTP(ctx context.Context, w http.ResponseWriter, r *ht
 
 func (ug *UserGroup) register(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	var anu APINewUser
-	if err := web.DecodeBody(r.Body, &anu); err != nil {
+	if err := web.DecodeBody(ctx, r, &anu); err != nil {
 		return err
 	}
 
@@ -172,7 +181,7 @@ func (ug *UserGroup) register(ctx context.Context, w http.ResponseWriter, r *htt
 
 func (ug *UserGroup) authenticate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	var aua APIUserAuthentication
-	if err := web.DecodeBody(r.Body, &aua); err != nil {
+	if err := web.DecodeBody(ctx, r, &aua); err != nil {
 		return err
 	}
 
@@ -185,6 +194,7 @@ func (ug *UserGroup) authenticate(ctx context.Context, w http.ResponseWriter, r
 			return web.EndUserError{
 				Message: "this email is not registered",
 				Status:  http.StatusBadRequest,
+				Err:     err,
 			}
 		}
 		if errors.Is(err, user.ErrWrongCredentials) {
@@ -202,8 +212,52 @@ func (ug *UserGroup) authenticate(ctx context.Context, w http.ResponseWriter, r
 }
 
 func (ug *UserGroup) Routes(group string) {
-	ug.app.Handle(http.MethodPost, group, "/verify-email", ug.verifyEmail)
-	ug.app.Handle(http.MethodPost, group, "/verify-otp", ug.verifyOTP)
-	ug.app.Handle(http.MethodPost, group, "/register", ug.register)
-	ug.app.Handle(http.MethodPost, group, "/login", ug.authenticate)
+	ug.router.Handle(http.MethodPost, group, "/verify-email", ug.verifyEmail, web.RouteDoc{
+		Summary:     "Send a verification code to an email",
+		Description: "The code is sent to the email unless one has been sent lately, Retry-After tells when another code can be asked for.",
+		Request:     APIEmailVerification{},
+		Response:    emailVerification{},
+		Errors: map[int][]string{
+			http.StatusBadRequest:         append(problems.Codes(user.ErrUniqueEmail), problems.Validation.Code),
+			http.StatusTooEarly:           problems.Codes(user.ErrEmailVerifySoon),
+			http.StatusServiceUnavailable: {problems.Unavailable.Code},
+		},
+	})
+	ug.router.Handle(http.MethodPost, group, "/verify-otp", ug.verifyOTP, web.RouteDoc{
+		Summary:     "Verify an email by the code sent to it",
+		Description: "The token in the response is the bearer token of register.",
+		Request:     APIOTPVerfication{},
+		Response:    token{},
+		Errors: map[int][]string{
+			http.StatusBadRequest:   {problems.Validation.Code},
+			http.StatusUnauthorized: problems.Codes(otp.ErrInvalidCode),
+		},
+	})
+	ug.router.Handle(http.MethodPost, group, "/register", ug.register, ug.idempotent, web.RouteDoc{
+		Summary:     "Register a user with a verified email",
+		Description: "Expects the token of verify-otp as the bearer token. Retries with the same Idempotency-Key header get the response of the first request.",
+		Request:     APINewUser{},
+		Status:      http.StatusCreated,
+		Auth:        true,
+		Errors: map[int][]string{
+			http.StatusBadRequest:          append(problems.Codes(user.ErrUniqueEmail), problems.Validation.Code, middlewares.CodeInvalidIdempotencyKey),
+			http.StatusUnauthorized:        problems.Codes(auth.ErrInvalidToken),
+			http.StatusConflict:            {middlewares.CodeIdempotencyInProgress},
+			http.StatusUnprocessableEntity: {middlewares.CodeIdempotencyKeyReused},
+		},
+	})
+	ug.router.Handle(http.MethodPost, group, "/login", ug.authenticate, web.RouteDoc{
+		Summary:  "Authenticate a user by email and password",
+		Request:  APIUserAuthentication{},
+		Response: token{},
+		Errors: map[int][]string{
+			http.StatusBadRequest:   append(problems.Codes(user.ErrUserNotFound), problems.Validation.Code),
+			http.StatusUnauthorized: problems.Codes(user.ErrWrongCredentials),
+		},
+	})
+}
+
+// seconds rounds the duration up to whole seconds as clients can not act on fractions
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
 }
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/so-heil/wishlist/business/email"
+	"github.com/so-heil/wishlist/business/storage/keyvalue/kvstores"
+	"github.com/so-heil/wishlist/business/web/middlewares"
 	"github.com/so-heil/wishlist/foundation/apitest"
 )
 
@@ -33,20 +35,26 @@ func TestUserGroup(t *testing.T) {
 
 	const group = "ug"
 	mailClient := newEmailClient()
+	kv := kvstores.NewFreeCache(64 << 20)
+	idempotent := middlewares.Idempotency(l, kv, middlewares.IdempotencyConfig{TTL: time.Minute, LockTTL: 10 * time.Second})
 	ug, err := New(Config{
 		EmailVerifyExp:           time.Second,
 		UserSessExp:              time.Second,
 		MailTimeout:              time.Second,
 		EmailVerificationSubject: "Email Verification",
-		CacheSize:                100_000,
 		OTPLength:                6,
 		OTPTimeout:               10 * time.Second,
-	}, mailClient, srv.App, srv.Auth, database.Dbase, l, "{{.}}")
+		OTPCooldown:              5 * time.Second,
+	}, mailClient, srv.App, idempotent, srv.Auth, database.Dbase, kv, l, "{{.}}")
 	if err != nil {
 		t.Fatalf("create usergroup: %s", err)
 	}
 	ug.Routes(group)
 
+	var verification struct {
+		ExpiresIn   int `json:"expires_in"`
+		ResendAfter int `json:"resend_after"`
+	}
 	verifyEmail := &apitest.Group{
 		Name:   "verifyEmail",
 		URL:    fmt.Sprintf("%s/%s%s", srv.URL, group, "/verify-email"),
@@ -55,7 +63,14 @@ func TestUserGroup(t *testing.T) {
 			{
 				Name:       "validEmail",
 				ReqBody:    `{"email": "test@test.com"}`,
-				StatusCode: http.StatusNoContent,
+				StatusCode: http.StatusOK,
+				RespDst:    &verification,
+				Validate: func() error {
+					if verification.ExpiresIn != 10 || verification.ResendAfter != 5 {
+						return fmt.Errorf("should report code expiry and resend cooldown, got: %+v", verification)
+					}
+					return nil
+				},
 			},
 			{
 				Name:       "invalidEmail",
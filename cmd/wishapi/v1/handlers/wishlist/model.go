@@ -0,0 +1,21 @@
+package wishlist
+
+import (
+	"context"
+
+	"github.com/so-heil/wishlist/business/validate"
+)
+
+// APINewProduct is a product added to a wishlist, its links are fetched by the server for
+// previews so they must point to public hosts
+type APINewProduct struct {
+	Name     string  `json:"name" validate:"required,max=200"`
+	Link     string  `json:"link" validate:"required,safeurl"`
+	ImageURL string  `json:"image_url" validate:"omitempty,safeurl"`
+	Price    float64 `json:"price" validate:"gte=0"`
+	Currency string  `json:"currency" validate:"required_with=Price,omitempty,currency"`
+}
+
+func (anp *APINewProduct) Validate(ctx context.Context) error {
+	return validate.Check(ctx, anp)
+}
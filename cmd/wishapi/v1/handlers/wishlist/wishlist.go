@@ -4,23 +4,42 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/so-heil/wishlist/business/web/problems"
 	"github.com/so-heil/wishlist/foundation/web"
 )
 
 type Wishlist struct {
-	app *web.App
+	router web.Router
 }
 
-func New(app *web.App) *Wishlist {
-	return &Wishlist{app: app}
+func New(router web.Router) *Wishlist {
+	return &Wishlist{router: router}
 }
 
-func Get(w http.ResponseWriter, r *http.Request, ctx context.Context) error {
+func (wl *Wishlist) get(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	return web.Respond(w, ctx, struct {
-		Ok bool `json:"ok"`
-	}{Ok: true}, http.StatusOK)
+		ID string `json:"id"`
+	}{ID: web.Param(r, "id")}, http.StatusOK)
+}
+
+func (wl *Wishlist) addProduct(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var anp APINewProduct
+	if err := web.DecodeBody(ctx, r, &anp); err != nil {
+		return err
+	}
+
+	return web.Respond(w, ctx, anp, http.StatusCreated)
 }
 
 func (wl *Wishlist) Routes(group string) {
-	wl.app.Handle(http.MethodGet, group, "/get", Get, nil)
+	wl.router.Handle(http.MethodGet, group, "/{id}", wl.get)
+	wl.router.Handle(http.MethodPost, group, "/{id}/products", wl.addProduct, web.RouteDoc{
+		Summary:  "Add a product to a wishlist",
+		Request:  APINewProduct{},
+		Response: APINewProduct{},
+		Status:   http.StatusCreated,
+		Errors: map[int][]string{
+			http.StatusBadRequest: {problems.Validation.Code},
+		},
+	})
 }
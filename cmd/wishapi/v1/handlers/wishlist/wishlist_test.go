@@ -0,0 +1,75 @@
+package wishlist
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/so-heil/wishlist/foundation/apitest"
+)
+
+func TestWishlist(t *testing.T) {
+	t.Parallel()
+	l, err := apitest.Logger(true)
+	if err != nil {
+		t.Fatalf("create logger: %s", err)
+	}
+
+	srv, err := apitest.NewAPIServer(apitest.DefaultAPIServerConfig, l)
+	if err != nil {
+		t.Fatalf("start api server: %s", err)
+	}
+	defer srv.Close()
+
+	const group = "wl"
+	New(srv.App).Routes(group)
+
+	var product APINewProduct
+	addProduct := &apitest.Group{
+		Name:   "addProduct",
+		URL:    fmt.Sprintf("%s/%s%s", srv.URL, group, "/7/products"),
+		Method: http.MethodPost,
+		Tests: []apitest.EndpointTest{
+			{
+				Name:       "valid",
+				ReqBody:    `{"name": "Lamp", "link": "https://shop.example.com/lamp", "image_url": "https://cdn.example.com/lamp.png", "price": 20, "currency": "EUR"}`,
+				StatusCode: http.StatusCreated,
+				RespDst:    &product,
+				Validate: func() error {
+					if product.Link != "https://shop.example.com/lamp" || product.Currency != "EUR" {
+						return fmt.Errorf("should respond with the product, got: %+v", product)
+					}
+					return nil
+				},
+			},
+			{
+				Name:       "privateLink",
+				ReqBody:    `{"name": "Lamp", "link": "http://10.0.0.1/admin"}`,
+				StatusCode: http.StatusBadRequest,
+			},
+			{
+				Name:       "loopbackImage",
+				ReqBody:    `{"name": "Lamp", "link": "https://shop.example.com/lamp", "image_url": "http://127.0.0.1/lamp.png"}`,
+				StatusCode: http.StatusBadRequest,
+			},
+			{
+				Name:       "withoutContentType",
+				ReqBody:    `{"name": "Lamp", "link": "https://shop.example.com/lamp"}`,
+				StatusCode: http.StatusUnsupportedMediaType,
+				Headers:    map[string]string{"Content-Type": ""},
+			},
+			{
+				Name:       "textContentType",
+				ReqBody:    `{"name": "Lamp", "link": "https://shop.example.com/lamp"}`,
+				StatusCode: http.StatusUnsupportedMediaType,
+				Headers:    map[string]string{"Content-Type": "text/plain"},
+			},
+			{
+				Name:       "unknownCurrency",
+				ReqBody:    `{"name": "Lamp", "link": "https://shop.example.com/lamp", "price": 20, "currency": "XYZ"}`,
+				StatusCode: http.StatusBadRequest,
+			},
+		},
+	}
+	addProduct.Run(t)
+}
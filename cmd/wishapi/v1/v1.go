@@ -0,0 +1,71 @@
+// Package v1 registers the handler groups of the API on the app, it is shared by wishapi
+// and the admin tool writing the OpenAPI document
+package v1
+
+import (
+	"fmt"
+
+	"github.com/so-heil/wishlist/business/auth"
+	"github.com/so-heil/wishlist/business/database/db"
+	"github.com/so-heil/wishlist/business/email"
+	"github.com/so-heil/wishlist/business/storage/keyvalue"
+	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/cmd/wishapi/v1/handlers/docs"
+	"github.com/so-heil/wishlist/cmd/wishapi/v1/handlers/usergrp"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.uber.org/zap"
+)
+
+// Config holds the dependencies of the handler groups, registering routes does not use
+// them so the zero value is enough to build the routes for documentation
+type Config struct {
+	App         *web.App
+	Log         *zap.SugaredLogger
+	Auth        *auth.Auth
+	DB          *db.DB
+	KV          keyvalue.KeyValueStore
+	Email       email.Client
+	Users       usergrp.Config
+	OTPTemplate string
+	Idempotency middlewares.IdempotencyConfig
+}
+
+type handlerGroup interface {
+	Routes(group string)
+}
+
+type handlerGroups map[string]handlerGroup
+
+func (g handlerGroups) handleAll() {
+	for name, group := range g {
+		group.Routes(name)
+	}
+}
+
+// Version is the name of the version in paths and Accept headers
+const Version = "v1"
+
+// idempotencyNamespace keeps stored responses apart from other keys of the shared store
+const idempotencyNamespace = "idempotency"
+
+// Routes registers every handler group under /v1 and returns the docs group serving their
+// OpenAPI document
+func Routes(cfg Config) (*docs.Docs, error) {
+	v1 := cfg.App.Version(Version, web.VersionConfig{})
+
+	idempotent := middlewares.Idempotency(cfg.Log, keyvalue.Namespace(cfg.KV, idempotencyNamespace), cfg.Idempotency)
+
+	userGroup, err := usergrp.New(cfg.Users, cfg.Email, v1, idempotent, cfg.Auth, cfg.DB, cfg.KV, cfg.Log, cfg.OTPTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("create usergroup: %w", err)
+	}
+
+	handlerGroups{
+		"users": userGroup,
+	}.handleAll()
+
+	d := docs.New(cfg.App)
+	d.Routes("")
+
+	return d, nil
+}
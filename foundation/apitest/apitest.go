@@ -19,7 +19,9 @@ type EndpointTest struct {
 	StatusCode int
 	RespDst    any
 	Validate   func() error
-	Headers    map[string]string
+	// Headers are set on the request, empty values remove the header. Requests with a body
+	// are sent as application/json unless Headers has a Content-Type.
+	Headers map[string]string
 }
 
 type Group struct {
@@ -40,10 +42,15 @@ func (g *Group) Run(t *testing.T) {
 				return
 			}
 
-			if tt.Headers != nil {
-				for k, v := range tt.Headers {
-					req.Header.Set(k, v)
+			if _, ok := tt.Headers["Content-Type"]; !ok && tt.ReqBody != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			for k, v := range tt.Headers {
+				if v == "" {
+					req.Header.Del(k)
+					continue
 				}
+				req.Header.Set(k, v)
 			}
 
 			resp, err := http.DefaultClient.Do(req)
@@ -1,17 +1,18 @@
 package apitest
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
-	"syscall"
 	"time"
 
 	"github.com/so-heil/wishlist/business/auth"
 	"github.com/so-heil/wishlist/business/keystore"
 	"github.com/so-heil/wishlist/business/validate"
 	"github.com/so-heil/wishlist/business/web/middlewares"
+	"github.com/so-heil/wishlist/foundation/lifecycle"
 	"github.com/so-heil/wishlist/foundation/web"
 	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
@@ -28,17 +29,17 @@ var DefaultAPIServerConfig = APIServerConfig{
 }
 
 type APIServer struct {
-	Log      *zap.SugaredLogger
-	shutdown chan os.Signal
-	srv      *httptest.Server
-	URL      string
-	Auth     *auth.Auth
-	App      *web.App
+	Log       *zap.SugaredLogger
+	lifecycle *lifecycle.Manager
+	srv       *httptest.Server
+	URL       string
+	Auth      *auth.Auth
+	App       *web.App
 }
 
 func NewAPIServer(config APIServerConfig, l *zap.SugaredLogger) (*APIServer, error) {
 	// init validate
-	if err := validate.Init(); err != nil {
+	if err := validate.Init(validate.Config{}); err != nil {
 		return nil, fmt.Errorf("init validator: %w", err)
 	}
 
@@ -47,33 +48,32 @@ func NewAPIServer(config APIServerConfig, l *zap.SugaredLogger) (*APIServer, err
 	app := web.NewApp(
 		l,
 		http.NewServeMux(),
-		[]web.Middleware{middlewares.Log(l), middlewares.Errors(l)},
+		[]web.Middleware{middlewares.RequestID(), middlewares.Locale(), middlewares.Log(l, middlewares.LogConfig{SuccessSampleRate: 1}), middlewares.Metrics(), middlewares.Errors(l), middlewares.Panics(), middlewares.BodyLimit(1 << 20)},
 		shutdown,
 		noop.TracerProvider{}.Tracer("noop"),
 	)
 
-	ks, err := keystore.New(config.KeystoreRotationDur, config.KeystoreExpirationDur, shutdown, l)
+	ks, err := keystore.New(config.KeystoreRotationDur, config.KeystoreExpirationDur, l)
 	if err != nil {
 		return nil, fmt.Errorf("create keystore: %w", err)
 	}
+	lc := lifecycle.New(l, time.Second)
+	lc.Go("keystore rotation", 0, ks.Rotate)
 
 	srv := httptest.NewServer(app)
 	return &APIServer{
-		Log:      l,
-		shutdown: shutdown,
-		srv:      srv,
-		URL:      srv.URL,
-		Auth:     auth.New(ks),
-		App:      app,
+		Log:       l,
+		lifecycle: lc,
+		srv:       srv,
+		URL:       srv.URL,
+		Auth:      auth.New(ks),
+		App:       app,
 	}, nil
 }
 
 func (s *APIServer) Close() error {
 	s.srv.Close()
-	s.shutdown <- syscall.SIGTERM
-	// wait for cleanups
-	time.Sleep(time.Second)
-	return nil
+	return s.lifecycle.Shutdown(context.Background())
 }
 
 func Logger(ignoreLogs bool) (*zap.SugaredLogger, error) {
@@ -0,0 +1,150 @@
+// Package health provides a registry of named dependency checks that readiness probes run
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = time.Second
+
+// Checker is implemented by dependencies able to report their own status
+type Checker interface {
+	StatusCheck(ctx context.Context) error
+}
+
+// Check describes a named dependency check
+type Check struct {
+	Name string
+	// Timeout bounds a single run of the check, it defaults to a second
+	Timeout time.Duration
+	// Critical checks make the service unready when they fail, others are only reported
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report holds the results of every check in registration order
+type Report struct {
+	Ready   bool
+	Results []Result
+}
+
+// Registry keeps the checks, it is safe to register checks while others run
+type Registry struct {
+	mu     sync.RWMutex
+	checks []Check
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the check to the registry, registering an unnamed check, a check
+// without Run or a name twice panics
+func (r *Registry) Register(c Check) {
+	if c.Name == "" || c.Run == nil {
+		panic("health: register check without name or run function")
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, registered := range r.checks {
+		if registered.Name == c.Name {
+			panic(fmt.Sprintf("health: check %q is already registered", c.Name))
+		}
+	}
+	r.checks = append(r.checks, c)
+}
+
+// RegisterChecker registers the StatusCheck of the checker
+func (r *Registry) RegisterChecker(name string, timeout time.Duration, critical bool, checker Checker) {
+	r.Register(Check{
+		Name:     name,
+		Timeout:  timeout,
+		Critical: critical,
+		Run:      checker.StatusCheck,
+	})
+}
+
+// Run runs every check concurrently, each bounded by its own timeout, the report is
+// ready unless a critical check fails
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]Check{}, r.checks...)
+	r.mu.RUnlock()
+
+	report := Report{
+		Ready:   true,
+		Results: make([]Result, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Results[i] = run(ctx, checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, res := range report.Results {
+		if res.Critical && res.Error != "" {
+			report.Ready = false
+		}
+	}
+
+	return report
+}
+
+// run returns when the check does or its timeout is reached, checks ignoring their
+// context are left running in the background
+func run(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{
+		Name:     c.Name,
+		Status:   "ok",
+		Critical: c.Critical,
+		Duration: time.Since(start).Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		res.Status = "failed"
+		res.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			res.Error = fmt.Sprintf("timed out after %s", c.Timeout)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,61 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/foundation/health"
+)
+
+func TestRegistry(t *testing.T) {
+	r := health.NewRegistry()
+	r.Register(health.Check{
+		Name:     "ok",
+		Critical: true,
+		Run:      func(ctx context.Context) error { return nil },
+	})
+	r.Register(health.Check{
+		Name: "optional",
+		Run:  func(ctx context.Context) error { return errors.New("down") },
+	})
+
+	report := r.Run(context.Background())
+	if !report.Ready {
+		t.Fatalf("should be ready when only non-critical checks fail: %+v", report)
+	}
+	if got := report.Results[1]; got.Status != "failed" || got.Error != "down" {
+		t.Errorf("should report the failed check, got: %+v", got)
+	}
+
+	// Checks ignoring their context should still be bounded by their timeout
+	r.Register(health.Check{
+		Name:     "slow",
+		Timeout:  50 * time.Millisecond,
+		Critical: true,
+		Run: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	start := time.Now()
+	report = r.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("run should not wait for a timed out check, took %s", elapsed)
+	}
+	if report.Ready {
+		t.Error("should not be ready when a critical check fails")
+	}
+	if got := report.Results[2]; got.Name != "slow" || got.Status != "failed" {
+		t.Errorf("results should keep registration order and report the timeout, got: %+v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic when registering a name twice")
+		}
+	}()
+	r.Register(health.Check{Name: "ok", Run: func(ctx context.Context) error { return nil }})
+}
@@ -0,0 +1,166 @@
+// Package lifecycle owns the components of an application, it broadcasts shutdown to
+// background workers by canceling a context and stops components in reverse start order
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// StopFunc stops a component, ctx is done when the timeout of the component is reached
+type StopFunc func(ctx context.Context) error
+
+// RunFunc is a background worker, it runs until ctx is canceled and returns nil when
+// it stopped because of ctx
+type RunFunc func(ctx context.Context) error
+
+type component struct {
+	name    string
+	timeout time.Duration
+	stop    StopFunc
+}
+
+// Manager stops the components added to it on Shutdown, components are added once they
+// are started so the ones started later, which may depend on earlier ones, stop first
+type Manager struct {
+	log     *zap.SugaredLogger
+	timeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	failed chan error
+
+	mu         sync.Mutex
+	components []component
+	shutdown   bool
+}
+
+// New creates a manager, components added without a timeout get the timeout, a zero
+// timeout defaults to 5 seconds
+func New(log *zap.SugaredLogger, timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		log:     log,
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+		failed:  make(chan error, 1),
+	}
+}
+
+// Context is canceled when shutdown starts
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Failed receives the error of the first worker stopping on its own before shutdown,
+// the application is expected to shut down on it
+func (m *Manager) Failed() <-chan error {
+	return m.failed
+}
+
+// Add registers a started component to be stopped on Shutdown
+func (m *Manager) Add(name string, timeout time.Duration, stop StopFunc) {
+	if timeout <= 0 {
+		timeout = m.timeout
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, timeout: timeout, stop: stop})
+}
+
+// AddCloser registers a started component stopped by closing it, Close is not bound by
+// the timeout but Shutdown stops waiting for it once the timeout is reached
+func (m *Manager) AddCloser(name string, timeout time.Duration, c io.Closer) {
+	m.Add(name, timeout, func(ctx context.Context) error {
+		closed := make(chan error, 1)
+		go func() {
+			closed <- c.Close()
+		}()
+
+		select {
+		case err := <-closed:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Go runs the worker in a goroutine with the context of the manager, stopping it waits
+// for the worker to return
+func (m *Manager) Go(name string, timeout time.Duration, run RunFunc) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := run(m.ctx); err != nil && m.ctx.Err() == nil {
+			m.log.Errorw("lifecycle: worker failed", "component", name, "ERROR", err)
+			select {
+			case m.failed <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}
+	}()
+
+	m.Add(name, timeout, func(ctx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Shutdown cancels the context of the manager and stops the components in reverse order,
+// each within its own timeout. A component failing to stop does not keep the others from
+// stopping, their errors are joined. Calling Shutdown again does nothing.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.shutdown {
+		m.mu.Unlock()
+		return nil
+	}
+	m.shutdown = true
+	components := m.components
+	m.mu.Unlock()
+
+	m.cancel()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		if err := m.stop(ctx, components[i]); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", components[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) stop(ctx context.Context, c component) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	m.log.Infow("shutdown: stopping", "component", c.name, "timeout", c.timeout)
+	start := time.Now()
+
+	if err := c.stop(ctx); err != nil {
+		m.log.Errorw("shutdown: stop failed", "component", c.name, "duration", time.Since(start), "ERROR", err)
+		return err
+	}
+
+	m.log.Infow("shutdown: stopped", "component", c.name, "duration", time.Since(start))
+	return nil
+}
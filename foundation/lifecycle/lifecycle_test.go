@@ -0,0 +1,102 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/so-heil/wishlist/foundation/lifecycle"
+	"go.uber.org/zap"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func TestShutdown(t *testing.T) {
+	m := lifecycle.New(zap.NewNop().Sugar(), time.Second)
+
+	var (
+		mu      sync.Mutex
+		stopped []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = append(stopped, name)
+	}
+
+	m.Add("database", 0, func(ctx context.Context) error {
+		record("database")
+		return nil
+	})
+	m.Go("worker", 0, func(ctx context.Context) error {
+		<-ctx.Done()
+		record("worker")
+		return nil
+	})
+	m.AddCloser("stuck", 50*time.Millisecond, closerFunc(func() error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+	m.Add("server", 0, func(ctx context.Context) error {
+		record("server")
+		return errors.New("connections left")
+	})
+
+	start := time.Now()
+	err := m.Shutdown(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("should stop waiting for components at their timeout, took: %s", time.Since(start))
+	}
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("should report the components failing to stop, got: %v", err)
+	}
+	if m.Context().Err() == nil {
+		t.Error("should cancel the context on shutdown")
+	}
+
+	want := []string{"server", "worker", "database"}
+	if !slices.Equal(stopped, want) {
+		t.Errorf("should stop components in reverse order, want %v got %v", want, stopped)
+	}
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Errorf("second shutdown should do nothing, got: %s", err)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	m := lifecycle.New(zap.NewNop().Sugar(), time.Second)
+
+	m.Go("failing", 0, func(ctx context.Context) error {
+		return errors.New("rotation failed")
+	})
+	m.Go("clean", 0, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	select {
+	case err := <-m.Failed():
+		if err.Error() != "failing: rotation failed" {
+			t.Errorf("should name the failed worker, got: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("should report the worker stopping before shutdown")
+	}
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Errorf("workers returning after shutdown should not fail it, got: %s", err)
+	}
+	select {
+	case err := <-m.Failed():
+		t.Errorf("workers stopping on shutdown should not be reported, got: %s", err)
+	default:
+	}
+}
@@ -0,0 +1,243 @@
+// Package openapi generates an OpenAPI 3.1 document from the routes registered on a web.App,
+// request and response types are reflected into JSON Schema including their validate tags
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/so-heil/wishlist/foundation/web"
+)
+
+const Version = "3.1.0"
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is the root object of an OpenAPI document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// bearerAuth names the security scheme of routes with RouteDoc.Auth
+const bearerAuth = "bearerAuth"
+
+// decodeErrors are the problems web.DecodeBody answers requests with a body with
+var decodeErrors = map[int][]string{
+	http.StatusBadRequest: {
+		"request.empty_body", "request.malformed_json", "request.invalid_type",
+		"request.unknown_field", "request.trailing_data", "request.malformed_body",
+	},
+	http.StatusRequestEntityTooLarge: {"request.body_too_large"},
+	http.StatusUnsupportedMediaType:  {"request.unsupported_media_type"},
+}
+
+// Generator builds documents, custom validate tags are described with RegisterTag
+type Generator struct {
+	tags       map[string]TagFunc
+	names      map[reflect.Type]string
+	components map[string]*Schema
+}
+
+func NewGenerator() *Generator {
+	return &Generator{
+		tags: builtinTags(),
+	}
+}
+
+// RegisterTag describes the validate tag in schemas of the fields using it
+func (g *Generator) RegisterTag(tag string, fn TagFunc) {
+	g.tags[tag] = fn
+}
+
+// Generate describes the routes, see web.App.Routes
+func (g *Generator) Generate(info Info, routes []web.RouteInfo) *Document {
+	g.names = make(map[reflect.Type]string)
+	g.components = make(map[string]*Schema)
+
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]*Operation),
+	}
+
+	problem := g.schema(reflect.TypeOf(web.Problem{}))
+	var auth bool
+
+	for _, route := range routes {
+		op := Operation{
+			OperationID: operationID(route.Method, route.Path),
+			Summary:     route.Doc.Summary,
+			Description: route.Doc.Description,
+			Tags:        route.Doc.Tags,
+			Parameters:  pathParams(route.Path),
+			Responses:   make(map[string]*Response),
+			Deprecated:  route.Deprecation != nil,
+		}
+		if len(op.Tags) == 0 && route.Group != "" {
+			op.Tags = []string{route.Group}
+		}
+
+		errs := make(map[int][]string)
+		for status, codes := range route.Doc.Errors {
+			errs[status] = append(errs[status], codes...)
+		}
+
+		if route.Doc.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]*MediaType{
+					"application/json": {Schema: g.schema(reflect.TypeOf(route.Doc.Request))},
+				},
+			}
+			for status, codes := range decodeErrors {
+				errs[status] = append(errs[status], codes...)
+			}
+		}
+
+		status := route.SuccessStatus()
+		success := Response{Description: http.StatusText(status)}
+		if route.Doc.Response != nil {
+			success.Content = map[string]*MediaType{
+				"application/json": {Schema: g.schema(reflect.TypeOf(route.Doc.Response))},
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = &success
+
+		for status, codes := range errs {
+			op.Responses[strconv.Itoa(status)] = &Response{
+				Description: errorDescription(status, codes),
+				Content:     map[string]*MediaType{web.MediaTypeProblem: {Schema: problem}},
+			}
+		}
+		op.Responses["default"] = &Response{
+			Description: "Unexpected error",
+			Content:     map[string]*MediaType{web.MediaTypeProblem: {Schema: problem}},
+		}
+
+		if route.Doc.Auth {
+			auth = true
+			op.Security = []map[string][]string{{bearerAuth: {}}}
+		}
+
+		ops, ok := doc.Paths[route.Path]
+		if !ok {
+			ops = make(map[string]*Operation)
+			doc.Paths[route.Path] = ops
+		}
+		ops[strings.ToLower(route.Method)] = &op
+	}
+
+	doc.Components.Schemas = g.components
+	if auth {
+		doc.Components.SecuritySchemes = map[string]*SecurityScheme{
+			bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		}
+	}
+
+	return &doc
+}
+
+// errorDescription lists the problem codes so clients know what to match on
+func errorDescription(status int, codes []string) string {
+	text := http.StatusText(status)
+	if len(codes) == 0 {
+		return text
+	}
+
+	codes = slices.Clone(codes)
+	sort.Strings(codes)
+	return text + ", problem codes: " + strings.Join(slices.Compact(codes), ", ")
+}
+
+var paramPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+func pathParams(path string) []Parameter {
+	var params []Parameter
+	for _, m := range paramPattern.FindAllStringSubmatch(path, -1) {
+		params = append(params, Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	return params
+}
+
+// operationID derives an id like postUsersVerifyEmail from the method and the path
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	upper := true
+	for _, r := range path {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			if upper && r >= 'a' && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/so-heil/wishlist/foundation/openapi"
+	"github.com/so-heil/wishlist/foundation/web"
+	"go.uber.org/zap"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type newUser struct {
+	Name     string   `json:"name" validate:"required,max=50"`
+	Email    string   `json:"email" validate:"required,email"`
+	Age      int      `json:"age" validate:"gte=18,lt=150"`
+	Role     string   `json:"role" validate:"omitempty,oneof=admin member"`
+	Tags     []string `json:"tags" validate:"max=5,dive,min=2"`
+	Home     address  `json:"home" validate:"required"`
+	Secret   string   `json:"-"`
+	Nickname string   `json:"nickname,omitempty" validate:"custom"`
+}
+
+type user struct {
+	ID string `json:"id"`
+}
+
+func TestGenerate(t *testing.T) {
+	app := web.NewApp(zap.NewNop().Sugar(), http.NewServeMux(), nil, make(chan os.Signal, 1), nil)
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error { return nil }
+
+	app.Handle(http.MethodPost, "users", "/register", noop, web.RouteDoc{
+		Summary:  "Register a user",
+		Request:  newUser{},
+		Response: user{},
+		Status:   http.StatusCreated,
+		Errors:   map[int][]string{http.StatusBadRequest: {"validation.failed"}},
+		Auth:     true,
+	})
+	app.Handle(http.MethodGet, "users", "/{id}", noop, web.Deprecation{})
+
+	g := openapi.NewGenerator()
+	g.RegisterTag("custom", openapi.Pattern("^[a-z]+$"))
+	doc := g.Generate(openapi.Info{Title: "test", Version: "1"}, app.Routes())
+
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("openapi version want %s got %s", openapi.Version, doc.OpenAPI)
+	}
+
+	register := doc.Paths["/users/register"]["post"]
+	if register == nil {
+		t.Fatalf("should describe POST /users/register, paths: %v", doc.Paths)
+	}
+	if register.OperationID != "postUsersRegister" || register.Summary != "Register a user" {
+		t.Errorf("unexpected operation id %q or summary %q", register.OperationID, register.Summary)
+	}
+	if !reflect.DeepEqual(register.Tags, []string{"users"}) {
+		t.Errorf("should be tagged by group, got %v", register.Tags)
+	}
+	if len(register.Security) != 1 || doc.Components.SecuritySchemes["bearerAuth"] == nil {
+		t.Error("should require the bearer security scheme")
+	}
+	for _, status := range []string{"201", "400", "413", "415", "default"} {
+		if register.Responses[status] == nil {
+			t.Errorf("should describe %s response", status)
+		}
+	}
+	if got := register.Responses["400"].Description; got != "Bad Request, problem codes: request.empty_body, request.invalid_type, request.malformed_body, request.malformed_json, request.trailing_data, request.unknown_field, validation.failed" {
+		t.Errorf("400 should list problem codes, got %q", got)
+	}
+	if ref := register.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/newUser" {
+		t.Errorf("request should reference its component, got %q", ref)
+	}
+
+	get := doc.Paths["/users/{id}"]["get"]
+	if get == nil || len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Fatalf("should describe the path parameter, got %+v", get)
+	}
+	if !get.Deprecated || register.Deprecated {
+		t.Error("only deprecated routes should be marked deprecated")
+	}
+
+	s := doc.Components.Schemas["newUser"]
+	if s == nil {
+		t.Fatal("should add newUser to components")
+	}
+	if !reflect.DeepEqual(s.Required, []string{"name", "email", "home"}) {
+		t.Errorf("required want [name email home] got %v", s.Required)
+	}
+	if _, ok := s.Properties["Secret"]; ok {
+		t.Error("should skip fields ignored by json")
+	}
+
+	checks := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "name maxLength", got: *s.Properties["name"].MaxLength, want: 50},
+		{name: "email format", got: s.Properties["email"].Format, want: "email"},
+		{name: "age minimum", got: *s.Properties["age"].Minimum, want: 18.0},
+		{name: "age exclusiveMaximum", got: *s.Properties["age"].ExclusiveMaximum, want: 150.0},
+		{name: "role enum", got: s.Properties["role"].Enum, want: []any{"admin", "member"}},
+		{name: "tags maxItems", got: *s.Properties["tags"].MaxItems, want: 5},
+		{name: "tags items ignore dive", got: s.Properties["tags"].Items.MinLength == nil, want: true},
+		{name: "home ref", got: s.Properties["home"].Ref, want: "#/components/schemas/address"},
+		{name: "custom tag", got: s.Properties["nickname"].Pattern, want: "^[a-z]+$"},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s want %v got %v", c.name, c.want, c.got)
+		}
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Errorf("document should marshal: %s", err)
+	}
+}
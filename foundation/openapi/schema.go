@@ -0,0 +1,270 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema used by OpenAPI 3.1 to describe request and response bodies
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// TagFunc applies a validate tag with its parameter, e.g. "8" for min=8, to the schema of a field
+type TagFunc func(s *Schema, param string)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema of t, named structs are added to the components and referenced
+func (g *Generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// component adds the schema of the named struct to the components once and returns its name,
+// types of different packages sharing a name are told apart by their package name
+func (g *Generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.components[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+
+	// Register the name before building the schema so recursive types end in a reference
+	g.names[t] = name
+	g.components[name] = nil
+	g.components[name] = g.object(t)
+	return name
+}
+
+// object describes the exported fields of a struct the way encoding/json marshals them
+func (g *Generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		fld := t.Field(i)
+		if !fld.IsExported() && !fld.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(fld.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Fields of embedded structs without a json name are promoted like encoding/json does
+		ft := fld.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if fld.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded := g.object(ft)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if !fld.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = fld.Name
+		}
+
+		fs := g.schema(fld.Type)
+		if g.applyTags(fs, fld.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+
+	return s
+}
+
+// applyTags applies the validate tags of a field to its schema and reports whether the field is required
+func (g *Generator) applyTags(s *Schema, tags string) bool {
+	if tags == "" || tags == "-" {
+		return false
+	}
+
+	// Constraints on references would be ignored by the referenced schema
+	if s.Ref != "" {
+		return strings.Contains(","+tags+",", ",required,")
+	}
+
+	var required bool
+	for _, tag := range strings.Split(tags, ",") {
+		name, param, _ := strings.Cut(tag, "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			// The tags that follow apply to elements
+			return required
+		default:
+			if fn, ok := g.tags[name]; ok {
+				fn(s, param)
+			}
+		}
+	}
+	return required
+}
+
+// builtinTags describes the tags of go-playground/validator that have a JSON Schema counterpart
+func builtinTags() map[string]TagFunc {
+	return map[string]TagFunc{
+		"email":    format("email"),
+		"url":      format("uri"),
+		"uri":      format("uri"),
+		"http_url": format("uri"),
+		"uuid":     format("uuid"),
+		"uuid4":    format("uuid"),
+		"datetime": format("date-time"),
+		"ip":       format("ip"),
+		"ipv4":     format("ipv4"),
+		"ipv6":     format("ipv6"),
+		"numeric":  Pattern(`^[-+]?[0-9]+(\.[0-9]+)?$`),
+		"number":   Pattern(`^[0-9]+$`),
+		"alpha":    Pattern(`^[a-zA-Z]+$`),
+		"alphanum": Pattern(`^[a-zA-Z0-9]+$`),
+		"iso4217":  Pattern(`^[A-Z]{3}$`),
+		"oneof": func(s *Schema, param string) {
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, v)
+			}
+		},
+		"min": bound(false, false),
+		"gte": bound(false, false),
+		"max": bound(true, false),
+		"lte": bound(true, false),
+		"gt":  bound(false, true),
+		"lt":  bound(true, true),
+		"len": func(s *Schema, param string) {
+			bound(false, false)(s, param)
+			bound(true, false)(s, param)
+		},
+	}
+}
+
+func format(f string) TagFunc {
+	return func(s *Schema, _ string) {
+		s.Format = f
+	}
+}
+
+// Pattern sets the pattern of string schemas
+func Pattern(pattern string) TagFunc {
+	return func(s *Schema, _ string) {
+		if s.Type == "string" {
+			s.Pattern = pattern
+		}
+	}
+}
+
+// bound applies a length, value or item count limit depending on the type of the schema
+// like validator does, exclusive limits only exist for numbers in JSON Schema
+func bound(upper, exclusive bool) TagFunc {
+	return func(s *Schema, param string) {
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+
+		switch s.Type {
+		case "string", "array":
+			count := int(n)
+			if exclusive && upper {
+				count--
+			} else if exclusive {
+				count++
+			}
+			switch {
+			case s.Type == "string" && upper:
+				s.MaxLength = &count
+			case s.Type == "string":
+				s.MinLength = &count
+			case upper:
+				s.MaxItems = &count
+			default:
+				s.MinItems = &count
+			}
+		case "integer", "number":
+			switch {
+			case upper && exclusive:
+				s.ExclusiveMaximum = &n
+			case upper:
+				s.Maximum = &n
+			case exclusive:
+				s.ExclusiveMinimum = &n
+			default:
+				s.Minimum = &n
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package tracing starts the OpenTelemetry trace provider with the exporter selected by config
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Supported exporters
+const (
+	ExporterOTLPHTTP = "otlp-http"
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterZipkin   = "zipkin"
+	ExporterStdout   = "stdout"
+	ExporterNone     = "none"
+)
+
+type Config struct {
+	ServiceName string
+	// Exporter is one of the supported exporters, none still records spans so trace ids
+	// are available to logs and error responses
+	Exporter string
+	// Endpoint is the collector URL, e.g. http://collector:4318/v1/traces for otlp-http,
+	// http://collector:4317 for otlp-grpc or http://zipkin:9411/api/v2/spans for zipkin.
+	// OTLP exporters use plain text on http endpoints and TLS on https endpoints.
+	Endpoint string
+	// Probability is the ratio of root traces sampled, incoming sampling decisions of the
+	// parent are honored
+	Probability float64
+	// Attributes are added to the resource of every span, e.g. kubernetes pod name
+	Attributes []attribute.KeyValue
+	// BatchTimeout is the maximum delay before spans are exported, defaults to a second
+	BatchTimeout time.Duration
+}
+
+// Start creates the trace provider and sets it and the W3C trace context and baggage
+// propagators as globals, the provider must be shut down to flush pending spans
+func Start(ctx context.Context, cfg Config) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", cfg.Exporter, err)
+	}
+
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = time.Second
+	}
+
+	attrs := append([]attribute.KeyValue{semconv.ServiceName(cfg.ServiceName)}, cfg.Attributes...)
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Probability))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(cfg.BatchTimeout)))
+	}
+
+	traceProvider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(traceProvider)
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return traceProvider, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New()
+	case ExporterZipkin:
+		return zipkin.New(cfg.Endpoint)
+	case ExporterOTLPHTTP:
+		u, insecure, err := otlpEndpoint(cfg.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+		if u.Path != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+		if insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case ExporterOTLPGRPC:
+		u, insecure, err := otlpEndpoint(cfg.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(u.Host)}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("exporter %q is not supported", cfg.Exporter)
+	}
+}
+
+func otlpEndpoint(endpoint string) (*url.URL, bool, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, false, fmt.Errorf("parse endpoint: %w", err)
+	}
+	if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, false, fmt.Errorf("endpoint %q should be an http or https URL", endpoint)
+	}
+	return u, u.Scheme == "http", nil
+}
@@ -0,0 +1,88 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/so-heil/wishlist/foundation/tracing"
+	"github.com/so-heil/wishlist/foundation/tracing/tracingtest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestStart(t *testing.T) {
+	collector, err := tracingtest.NewCollector()
+	if err != nil {
+		t.Fatalf("start collector: %s", err)
+	}
+	defer collector.Close()
+
+	for _, tt := range []struct {
+		exporter string
+		endpoint string
+	}{
+		{exporter: tracing.ExporterOTLPHTTP, endpoint: collector.HTTPEndpoint},
+		{exporter: tracing.ExporterOTLPGRPC, endpoint: collector.GRPCEndpoint},
+	} {
+		t.Run(tt.exporter, func(t *testing.T) {
+			ctx := context.Background()
+			tp, err := tracing.Start(ctx, tracing.Config{
+				ServiceName: "tracingtest",
+				Exporter:    tt.exporter,
+				Endpoint:    tt.endpoint,
+				Probability: 0,
+				Attributes:  []attribute.KeyValue{attribute.String("k8s.pod.name", "pod-1")},
+			})
+			if err != nil {
+				t.Fatalf("start tracing: %s", err)
+			}
+
+			// Probability is zero, only the sampled parent should get the span exported
+			const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+			header := http.Header{}
+			header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+			parent := otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+
+			_, span := tp.Tracer("test").Start(parent, tt.exporter)
+			span.End()
+			_, dropped := tp.Tracer("test").Start(ctx, tt.exporter+"-dropped")
+			dropped.End()
+
+			if err := tp.Shutdown(ctx); err != nil {
+				t.Fatalf("shutdown should flush spans: %s", err)
+			}
+
+			var found bool
+			for _, s := range collector.Spans() {
+				if s.GetName() == tt.exporter+"-dropped" {
+					t.Error("root span should not be sampled with zero probability")
+				}
+				if s.GetName() != tt.exporter {
+					continue
+				}
+				found = true
+				if got := hex.EncodeToString(s.GetTraceId()); got != traceID {
+					t.Errorf("span should join the incoming trace, trace id: %s", got)
+				}
+				if s.Resource["service.name"] != "tracingtest" || s.Resource["k8s.pod.name"] != "pod-1" {
+					t.Errorf("span should carry resource attributes, got: %v", s.Resource)
+				}
+			}
+			if !found {
+				t.Error("collector should receive the span")
+			}
+		})
+	}
+}
+
+func TestStartUnsupported(t *testing.T) {
+	if _, err := tracing.Start(context.Background(), tracing.Config{Exporter: "jaeger"}); err == nil {
+		t.Error("should not start with an unsupported exporter")
+	}
+	if _, err := tracing.Start(context.Background(), tracing.Config{Exporter: tracing.ExporterOTLPHTTP, Endpoint: "collector:4318"}); err == nil {
+		t.Error("should require an endpoint URL for OTLP")
+	}
+}
@@ -0,0 +1,123 @@
+// Package tracingtest provides an in-process OTLP collector to assert on exported spans in tests
+package tracingtest
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Collector accepts OTLP trace exports over HTTP and gRPC and keeps the received spans
+type Collector struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	// HTTPEndpoint is the URL to use with the otlp-http exporter
+	HTTPEndpoint string
+	// GRPCEndpoint is the URL to use with the otlp-grpc exporter
+	GRPCEndpoint string
+
+	httpSrv *httptest.Server
+	grpcSrv *grpc.Server
+
+	mu    sync.Mutex
+	spans []*tracepb.ResourceSpans
+}
+
+// NewCollector starts listening on local ports, Close has to be called to stop it
+func NewCollector() (*Collector, error) {
+	c := &Collector{}
+
+	c.httpSrv = httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+	c.HTTPEndpoint = c.httpSrv.URL + "/v1/traces"
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		c.httpSrv.Close()
+		return nil, err
+	}
+	c.grpcSrv = grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(c.grpcSrv, c)
+	go c.grpcSrv.Serve(lis)
+	c.GRPCEndpoint = "http://" + lis.Addr().String()
+
+	return c, nil
+}
+
+func (c *Collector) Close() {
+	c.httpSrv.Close()
+	c.grpcSrv.Stop()
+}
+
+// Export implements the OTLP trace service over gRPC
+func (c *Collector) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.add(req)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func (c *Collector) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.add(&req)
+
+	resp, err := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(resp)
+}
+
+func (c *Collector) add(req *coltracepb.ExportTraceServiceRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spans = append(c.spans, req.GetResourceSpans()...)
+}
+
+// Span is a received span with the attributes of its resource
+type Span struct {
+	*tracepb.Span
+	Resource map[string]string
+}
+
+// Spans returns the spans received so far
+func (c *Collector) Spans() []Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var spans []Span
+	for _, rs := range c.spans {
+		res := make(map[string]string)
+		for _, attr := range rs.GetResource().GetAttributes() {
+			res[attr.GetKey()] = attr.GetValue().GetStringValue()
+		}
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				spans = append(spans, Span{Span: s, Resource: res})
+			}
+		}
+	}
+	return spans
+}
@@ -2,11 +2,13 @@ package web
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
 )
 
 type ctxKey int
@@ -20,6 +22,16 @@ type Values struct {
 	Tracer     trace.Tracer
 	Now        time.Time
 	StatusCode int
+	// Route is the path pattern the request has been routed by, e.g. /wishlists/{id}
+	Route string
+	// RequestID identifies the request between services, see middlewares.RequestID
+	RequestID string
+	// Logger carries the fields of the request, see GetLogger
+	Logger *zap.SugaredLogger
+
+	// request and compressMin let Respond negotiate the response
+	request     *http.Request
+	compressMin int
 }
 
 // GetValues returns the values from the context.
@@ -79,6 +91,36 @@ func SetStatusCode(ctx context.Context, statusCode int) {
 	v.StatusCode = statusCode
 }
 
+// SetLogger sets the logger of the request so every log of the request carries the same fields.
+func SetLogger(ctx context.Context, l *zap.SugaredLogger) {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return
+	}
+
+	v.Logger = l
+}
+
+// AddLogFields adds key-value pairs to the logger of the request, e.g. the user id after authentication.
+func AddLogFields(ctx context.Context, keysAndValues ...any) {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok || v.Logger == nil {
+		return
+	}
+
+	v.Logger = v.Logger.With(keysAndValues...)
+}
+
+// GetLogger returns the logger of the request, or the fallback outside of requests
+// and before a logger has been set.
+func GetLogger(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok || v.Logger == nil {
+		return fallback
+	}
+	return v.Logger
+}
+
 func setValues(ctx context.Context, v *Values) context.Context {
 	return context.WithValue(ctx, key, v)
 }
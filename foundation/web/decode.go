@@ -0,0 +1,153 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type validator interface {
+	Validate(ctx context.Context) error
+}
+
+// DecodeBody decodes the JSON body of the request into dst and validates it if dst has a
+// Validate method. Requests with another content type are answered with 415, bodies over
+// the limit set by http.MaxBytesReader, e.g. by middlewares.BodyLimit, with 413 and any
+// other decode failure with 400 and a message pointing at the problem.
+func DecodeBody(ctx context.Context, r *http.Request, dst any) error {
+	if err := checkContentType(r.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+
+	// The body should hold a single value, anything but EOF after it is trailing data
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return decodeError(err)
+		}
+		return EndUserError{
+			Message: "request body must only contain a single JSON value",
+			Code:    "request.trailing_data",
+			Status:  http.StatusBadRequest,
+		}
+	}
+
+	v, ok := dst.(validator)
+	if ok {
+		if err := v.Validate(ctx); err != nil {
+			return fmt.Errorf("validation: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func checkContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	return EndUserError{
+		Message: "Content-Type header should be application/json",
+		Code:    "request.unsupported_media_type",
+		Status:  http.StatusUnsupportedMediaType,
+	}
+}
+
+func decodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		mbe       *http.MaxBytesError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return EndUserError{
+			Message: "request body is empty",
+			Code:    "request.empty_body",
+			Status:  http.StatusBadRequest,
+		}
+	case errors.As(err, &mbe):
+		return EndUserError{
+			Message: fmt.Sprintf("request body should not be larger than %d bytes", mbe.Limit),
+			Code:    "request.body_too_large",
+			Status:  http.StatusRequestEntityTooLarge,
+			Params:  []string{strconv.FormatInt(mbe.Limit, 10)},
+		}
+	case errors.As(err, &syntaxErr):
+		return EndUserError{
+			Message: fmt.Sprintf("request body has malformed JSON at position %d", syntaxErr.Offset),
+			Code:    "request.malformed_json",
+			Status:  http.StatusBadRequest,
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return EndUserError{
+			Message: "request body has malformed JSON",
+			Code:    "request.malformed_json",
+			Status:  http.StatusBadRequest,
+		}
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return EndUserError{
+				Message: fmt.Sprintf("request body should be a JSON %s", jsonKind(typeErr.Type.Kind())),
+				Code:    "request.invalid_type",
+				Status:  http.StatusBadRequest,
+			}
+		}
+		return EndUserError{
+			Message: fmt.Sprintf("request body has a %s value for field %q", typeErr.Value, typeErr.Field),
+			Code:    "request.invalid_type",
+			Status:  http.StatusBadRequest,
+			Params:  []string{typeErr.Field},
+			Fields: map[string]string{
+				typeErr.Field: fmt.Sprintf("should be a %s", jsonKind(typeErr.Type.Kind())),
+			},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return EndUserError{
+			Message: fmt.Sprintf("request body has unknown field %s", field),
+			Code:    "request.unknown_field",
+			Status:  http.StatusBadRequest,
+			Params:  []string{field},
+		}
+	default:
+		return EndUserError{
+			Message: "request body is malformed",
+			Code:    "request.malformed_body",
+			Status:  http.StatusBadRequest,
+		}
+	}
+}
+
+// jsonKind names go kinds the way clients writing JSON know them
+func jsonKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "number"
+	}
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+	type person struct {
+		Name    string  `json:"name"`
+		Age     int     `json:"age"`
+		Address address `json:"address"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		limit       int64
+		status      int
+		message     string
+		field       string
+	}{
+		{name: "valid", contentType: "application/json; charset=utf-8", body: `{"name":"a","age":1}`},
+		{name: "jsonSuffix", contentType: "application/merge-patch+json", body: `{"name":"a"}`},
+		{name: "noContentType", body: `{"name":"a"}`, status: http.StatusUnsupportedMediaType},
+		{name: "form", contentType: "application/x-www-form-urlencoded", body: "name=a", status: http.StatusUnsupportedMediaType},
+		{name: "empty", contentType: "application/json", status: http.StatusBadRequest, message: "request body is empty"},
+		{name: "syntax", contentType: "application/json", body: `{"name":"a",}`, status: http.StatusBadRequest, message: "request body has malformed JSON at position 13"},
+		{name: "truncated", contentType: "application/json", body: `{"name":"a"`, status: http.StatusBadRequest, message: "request body has malformed JSON"},
+		{name: "unknownField", contentType: "application/json", body: `{"nickname":"a"}`, status: http.StatusBadRequest, message: `request body has unknown field "nickname"`},
+		{name: "typeMismatch", contentType: "application/json", body: `{"address":{"city":12}}`, status: http.StatusBadRequest, message: `request body has a number value for field "address.city"`, field: "address.city"},
+		{name: "notObject", contentType: "application/json", body: `[]`, status: http.StatusBadRequest, message: "request body should be a JSON object"},
+		{name: "trailing", contentType: "application/json", body: `{"name":"a"}{"name":"b"}`, status: http.StatusBadRequest, message: "request body must only contain a single JSON value"},
+		{name: "tooLarge", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, limit: 32, status: http.StatusRequestEntityTooLarge, message: "request body should not be larger than 32 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.limit != 0 {
+				r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, tt.limit)
+			}
+
+			var p person
+			err := DecodeBody(r.Context(), r, &p)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("should decode body: %s", err)
+				}
+				return
+			}
+
+			var eue EndUserError
+			if !errors.As(err, &eue) {
+				t.Fatalf("should return an end user error, got: %v", err)
+			}
+			if eue.Status != tt.status {
+				t.Errorf("status want %d got %d", tt.status, eue.Status)
+			}
+			if tt.message != "" && eue.Message != tt.message {
+				t.Errorf("message want %q got %q", tt.message, eue.Message)
+			}
+			if tt.field != "" && eue.Fields[tt.field] == "" {
+				t.Errorf("should point at field %q, got: %v", tt.field, eue.Fields)
+			}
+		})
+	}
+}
@@ -10,15 +10,26 @@ type EndUserError struct {
 	Message string            `json:"message"`
 	Fields  map[string]string `json:"fields,omitempty"`
 	Status  int               `json:"-"`
+	// Code is the stable machine-readable code of the error, see Problem
+	Code string `json:"code,omitempty"`
+	// Params are the values interpolated in Message, they let the message be translated by its code
+	Params []string `json:"-"`
+	// Err is the cause of the error, it lets callers match sentinel errors with errors.Is
+	Err error `json:"-"`
 }
 
 func EUEFromError(err error, status int) EndUserError {
 	return EndUserError{
 		Message: err.Error(),
 		Status:  status,
+		Err:     err,
 	}
 }
 
+func (eue EndUserError) Unwrap() error {
+	return eue.Err
+}
+
 func IsEndUserError(err error) bool {
 	var eue EndUserError
 	return errors.As(err, &eue)
@@ -46,3 +57,22 @@ func IsExternalError(err error) bool {
 	var ee ExternalError
 	return errors.As(err, &ee)
 }
+
+// ShutdownError is returned when the application has lost its integrity and needs to shut
+// down, it is the only error that escaping the middlewares stops the server
+type ShutdownError struct {
+	Message string
+}
+
+func NewShutdownError(message string) error {
+	return &ShutdownError{Message: message}
+}
+
+func (se *ShutdownError) Error() string {
+	return se.Message
+}
+
+func IsShutdown(err error) bool {
+	var se *ShutdownError
+	return errors.As(err, &se)
+}
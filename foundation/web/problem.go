@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const MediaTypeProblem = "application/problem+json"
+
+// CodeInternal is the code of problems hiding an unexpected failure, it is shared by every
+// layer answering one so clients and translations see a single code
+const CodeInternal = "internal"
+
+// Problem is an RFC 9457 problem details object, Code and Fields are extension members
+type Problem struct {
+	// Type identifies the problem type, about:blank when the problem has no type of its own
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance identifies the occurrence, it is the trace id of the request
+	Instance string `json:"instance,omitempty"`
+	// Code is stable for a problem type so clients can match it instead of the detail
+	Code   string            `json:"code"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// StatusCode returns a code for problems without a code of their own, e.g. too_early for 425
+func StatusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "unknown"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// RespondProblem sends the problem as application/problem+json, missing type, title
+// and code are filled from the status and the trace id of the request is the instance
+func RespondProblem(w http.ResponseWriter, ctx context.Context, p Problem) error {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Code == "" {
+		p.Code = StatusCode(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = GetTraceID(ctx)
+	}
+
+	jsn, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal problem: %w", err)
+	}
+
+	SetStatusCode(ctx, p.Status)
+	w.Header().Set("Content-Type", MediaTypeProblem)
+	w.WriteHeader(p.Status)
+
+	if _, err := w.Write(jsn); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Media types Respond can encode list responses in, single values are always JSON
+const (
+	MediaTypeJSON   = "application/json"
+	MediaTypeNDJSON = "application/x-ndjson"
+	MediaTypeCSV    = "text/csv"
+)
+
+const defaultCompressMin = 1024
+
+var (
+	listMediaTypes = []string{MediaTypeJSON, MediaTypeNDJSON, MediaTypeCSV}
+	// Encodings in order of preference when the client accepts several equally
+	encodings = []string{"br", "zstd", "gzip"}
+
+	// zstdEncoder is safe for concurrent use through EncodeAll
+	zstdEncoder, _ = zstd.NewWriter(nil)
+)
+
+// Respond encodes data in the media type of the request's Accept header, JSON unless a list
+// is asked for as NDJSON or CSV, and compresses it with the best encoding the client accepts
+// when it is above the compression threshold of the App. Successful GET responses carry an
+// ETag and are answered with 304 when the client's If-None-Match matches it.
+func Respond(w http.ResponseWriter, ctx context.Context, data any, statusCode int) error {
+	if statusCode == http.StatusNoContent || data == nil {
+		SetStatusCode(ctx, statusCode)
+		w.WriteHeader(statusCode)
+		return nil
+	}
+
+	v := GetValues(ctx)
+	var header http.Header
+	if v.request != nil {
+		header = v.request.Header
+	}
+
+	mediaType := MediaTypeJSON
+	if isList(data) {
+		w.Header().Add("Vary", "Accept")
+		mediaType = negotiate(header.Get("Accept"), listMediaTypes)
+		if mediaType == "" {
+			mediaType = MediaTypeJSON
+		}
+	}
+
+	body, contentType, err := encode(data, mediaType)
+	if err != nil {
+		return fmt.Errorf("encode response: %w", err)
+	}
+
+	if statusCode == http.StatusOK && v.request != nil && (v.request.Method == http.MethodGet || v.request.Method == http.MethodHead) {
+		etag := etagOf(body, contentType)
+		w.Header().Set("ETag", etag)
+		if etagMatches(header.Get("If-None-Match"), etag) {
+			SetStatusCode(ctx, http.StatusNotModified)
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	if v.request != nil && v.compressMin >= 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if len(body) >= v.compressMin {
+			if encoding := negotiate(header.Get("Accept-Encoding"), encodings); encoding != "" {
+				compressed, err := compress(body, encoding)
+				if err != nil {
+					return fmt.Errorf("compress response: %w", err)
+				}
+				body = compressed
+				w.Header().Set("Content-Encoding", encoding)
+			}
+		}
+	}
+
+	SetStatusCode(ctx, statusCode)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	return nil
+}
+
+func isList(data any) bool {
+	k := reflect.TypeOf(data).Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+func encode(data any, mediaType string) ([]byte, string, error) {
+	switch mediaType {
+	case MediaTypeNDJSON:
+		body, err := encodeNDJSON(data)
+		return body, MediaTypeNDJSON, err
+	case MediaTypeCSV:
+		body, err := encodeCSV(data)
+		return body, MediaTypeCSV + "; charset=utf-8", err
+	default:
+		body, err := json.Marshal(data)
+		return body, MediaTypeJSON, err
+	}
+}
+
+func encodeNDJSON(list any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	lv := reflect.ValueOf(list)
+	for i := 0; i < lv.Len(); i++ {
+		if err := enc.Encode(lv.Index(i).Interface()); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeCSV writes a row per item, the columns of struct items are their JSON fields and
+// non-scalar values are written as JSON
+func encodeCSV(list any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	cw := csv.NewWriter(buf)
+
+	lv := reflect.ValueOf(list)
+	et := lv.Type().Elem()
+	for et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+
+	if et.Kind() != reflect.Struct {
+		if err := cw.Write([]string{"value"}); err != nil {
+			return nil, err
+		}
+		for i := 0; i < lv.Len(); i++ {
+			cell, err := csvCell(lv.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			if err := cw.Write([]string{cell}); err != nil {
+				return nil, err
+			}
+		}
+		cw.Flush()
+		return buf.Bytes(), cw.Error()
+	}
+
+	var (
+		names  []string
+		fields []int
+	)
+	for i := 0; i < et.NumField(); i++ {
+		f := et.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+		fields = append(fields, i)
+	}
+	if err := cw.Write(names); err != nil {
+		return nil, err
+	}
+
+	row := make([]string, len(fields))
+	for i := 0; i < lv.Len(); i++ {
+		item := reflect.Indirect(lv.Index(i))
+		for j, f := range fields {
+			if !item.IsValid() {
+				row[j] = ""
+				continue
+			}
+			cell, err := csvCell(item.Field(f))
+			if err != nil {
+				return nil, fmt.Errorf("item %d field %s: %w", i, names[j], err)
+			}
+			row[j] = cell
+		}
+		if err := cw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
+
+func csvCell(v reflect.Value) (string, error) {
+	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return "", nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	}
+
+	jsn, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(string(jsn), `"`), nil
+}
+
+func compress(body []byte, encoding string) ([]byte, error) {
+	if encoding == "zstd" {
+		return zstdEncoder.EncodeAll(body, make([]byte, 0, len(body))), nil
+	}
+
+	buf := new(bytes.Buffer)
+	var cw interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch encoding {
+	case "br":
+		cw = brotli.NewWriterLevel(buf, brotli.DefaultCompression)
+	default:
+		cw = gzip.NewWriter(buf)
+	}
+
+	if _, err := cw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// etagOf is weak as the same representation may be sent with different content encodings
+func etagOf(body []byte, contentType string) string {
+	h := sha256.New()
+	h.Write([]byte(contentType))
+	h.Write(body)
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches uses the weak comparison of If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate picks the offer with the highest quality in the Accept or Accept-Encoding
+// header, ties are broken by the order of offers, an empty header or no acceptable offer
+// yields an empty string
+func negotiate(header string, offers []string) string {
+	if strings.TrimSpace(header) == "" {
+		return ""
+	}
+
+	type accepted struct {
+		value string
+		q     float64
+	}
+	var specs []accepted
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(k) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		specs = append(specs, accepted{value: value, q: q})
+	}
+
+	// Exact values take precedence over wildcards regardless of their order
+	specificity := func(value string) int {
+		switch {
+		case value == "*" || value == "*/*":
+			return 0
+		case strings.HasSuffix(value, "/*"):
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specificity(specs[i].value) > specificity(specs[j].value)
+	})
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		for _, spec := range specs {
+			if !matches(spec.value, offer) {
+				continue
+			}
+			if spec.q > bestQ {
+				best, bestQ = offer, spec.q
+			}
+			break
+		}
+	}
+	return best
+}
+
+func matches(spec, offer string) bool {
+	switch {
+	case spec == "*" || spec == "*/*":
+		return true
+	case strings.HasSuffix(spec, "/*"):
+		return strings.HasPrefix(offer, strings.TrimSuffix(spec, "*"))
+	default:
+		return spec == offer
+	}
+}
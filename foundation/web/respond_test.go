@@ -0,0 +1,157 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestRespond(t *testing.T) {
+	app, url, close := runApp(t)
+	defer close()
+
+	type item struct {
+		ID     int      `json:"id"`
+		Name   string   `json:"name"`
+		Tags   []string `json:"tags"`
+		secret string
+	}
+	items := make([]item, 50)
+	for i := range items {
+		items[i] = item{ID: i, Name: "item, with comma", Tags: []string{"a", "b"}}
+	}
+
+	app.Handle(http.MethodGet, "respond", "/list", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return Respond(w, ctx, items, http.StatusOK)
+	})
+	app.Handle(http.MethodGet, "respond", "/single", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return Respond(w, ctx, items[0], http.StatusOK)
+	})
+
+	// The default transport would negotiate gzip and decode it transparently
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	get := func(path string, header map[string]string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		if err != nil {
+			t.Fatalf("create request: %s", err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("should be able to call handler over http: %s", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read body: %s", err)
+		}
+		return resp, body
+	}
+
+	t.Run("encodings", func(t *testing.T) {
+		decoders := map[string]func(io.Reader) (io.Reader, error){
+			"": func(r io.Reader) (io.Reader, error) { return r, nil },
+			"gzip": func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+			"br": func(r io.Reader) (io.Reader, error) {
+				return brotli.NewReader(r), nil
+			},
+			"zstd": func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		}
+
+		for acceptEncoding, want := range map[string]string{
+			"":                    "",
+			"gzip":                "gzip",
+			"gzip, br":            "br",
+			"zstd;q=1, br;q=0.5":  "zstd",
+			"identity":            "",
+			"*;q=0.1, gzip;q=0.9": "gzip",
+		} {
+			resp, body := get("/respond/list", map[string]string{"Accept-Encoding": acceptEncoding})
+			if got := resp.Header.Get("Content-Encoding"); got != want {
+				t.Errorf("Accept-Encoding %q: encoding want %q got %q", acceptEncoding, want, got)
+				continue
+			}
+			r, err := decoders[want](bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("create %s decoder: %s", want, err)
+			}
+			var got []item
+			if err := json.NewDecoder(r).Decode(&got); err != nil || len(got) != len(items) {
+				t.Errorf("Accept-Encoding %q: should decode items, got %d, err: %v", acceptEncoding, len(got), err)
+			}
+		}
+
+		if resp, _ := get("/respond/single", map[string]string{"Accept-Encoding": "gzip"}); resp.Header.Get("Content-Encoding") != "" {
+			t.Error("should not compress responses under the threshold")
+		}
+	})
+
+	t.Run("mediaTypes", func(t *testing.T) {
+		resp, body := get("/respond/list", map[string]string{"Accept": "application/x-ndjson"})
+		if resp.Header.Get("Content-Type") != MediaTypeNDJSON {
+			t.Fatalf("should respond with NDJSON, got: %s", resp.Header.Get("Content-Type"))
+		}
+		var lines int
+		for sc := bufio.NewScanner(bytes.NewReader(body)); sc.Scan(); lines++ {
+			var it item
+			if err := json.Unmarshal(sc.Bytes(), &it); err != nil {
+				t.Fatalf("line %d should be a JSON item: %s", lines, err)
+			}
+		}
+		if lines != len(items) {
+			t.Errorf("should have a line per item, got: %d", lines)
+		}
+
+		resp, body = get("/respond/list", map[string]string{"Accept": "text/csv;q=0.9, application/json;q=0.5"})
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), MediaTypeCSV) {
+			t.Fatalf("should respond with CSV, got: %s", resp.Header.Get("Content-Type"))
+		}
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		if err != nil {
+			t.Fatalf("should be valid CSV: %s", err)
+		}
+		if len(records) != len(items)+1 || strings.Join(records[0], ",") != "id,name,tags" {
+			t.Fatalf("should have a header and a row per item, got %d rows, header: %v", len(records), records[0])
+		}
+		if records[1][1] != "item, with comma" || records[1][2] != `["a","b"]` {
+			t.Errorf("row should hold the fields, got: %v", records[1])
+		}
+
+		if resp, _ := get("/respond/single", map[string]string{"Accept": "text/csv"}); resp.Header.Get("Content-Type") != MediaTypeJSON {
+			t.Errorf("single values should be JSON, got: %s", resp.Header.Get("Content-Type"))
+		}
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		resp, _ := get("/respond/single", nil)
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Fatal("should set an ETag")
+		}
+
+		resp, body := get("/respond/single", map[string]string{"If-None-Match": `"other", ` + etag})
+		if resp.StatusCode != http.StatusNotModified || len(body) != 0 {
+			t.Errorf("should answer a matching If-None-Match with an empty 304, got %d with %d bytes", resp.StatusCode, len(body))
+		}
+
+		if resp, _ := get("/respond/single", map[string]string{"If-None-Match": `"other"`}); resp.StatusCode != http.StatusOK {
+			t.Errorf("should answer a stale If-None-Match with 200, got: %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RouteOption customizes a route registered by App.Handle, it is either a Middleware of the
+// route, its Deprecation or its RouteDoc
+type RouteOption interface {
+	applyRoute(*routeConfig)
+}
+
+type routeConfig struct {
+	mw          []Middleware
+	doc         RouteDoc
+	deprecation *Deprecation
+}
+
+func newRouteConfig(opts []RouteOption) routeConfig {
+	var rc routeConfig
+	for _, opt := range opts {
+		opt.applyRoute(&rc)
+	}
+	return rc
+}
+
+// wrap applies the middlewares of the route to the handler, deprecation headers are set
+// before any of them runs so early responses carry them too
+func (rc routeConfig) wrap(handler Handler) Handler {
+	handler = applyMiddlewares(handler, rc.mw)
+	if rc.deprecation != nil {
+		handler = rc.deprecation.middleware()(handler)
+	}
+	return handler
+}
+
+func (mw Middleware) applyRoute(rc *routeConfig) {
+	rc.mw = append(rc.mw, mw)
+}
+
+// RouteDoc describes a route for API documentation, see App.Routes
+type RouteDoc struct {
+	Summary     string
+	Description string
+	// Tags group routes in the documentation, the group of the route is used when empty
+	Tags []string
+	// Request is a value of the type decoded from the request body, e.g. APINewUser{}
+	Request any
+	// Response is a value of the type sent on success, nil for routes without a body
+	Response any
+	// Status is the status code of a successful response, 200 when zero
+	Status int
+	// Errors lists the problem codes the route answers with by status code
+	Errors map[int][]string
+	// Auth marks routes expecting a bearer token in the Authorization header
+	Auth bool
+}
+
+func (rd RouteDoc) applyRoute(rc *routeConfig) {
+	rc.doc = rd
+}
+
+// Deprecation announces that a route or a version is going away with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) response headers
+type Deprecation struct {
+	// At is when the route has been or will be deprecated, the zero time means it already is
+	// and sends the "true" value of the drafts of RFC 9745 as there is no date to tell
+	At time.Time
+	// Sunset is when the route will stop answering, the header is left out when zero
+	Sunset time.Time
+	// Link points to documentation about the deprecation, e.g. a migration guide
+	Link string
+}
+
+func (d Deprecation) applyRoute(rc *routeConfig) {
+	rc.deprecation = &d
+}
+
+func (d Deprecation) middleware() Middleware {
+	deprecation := "true"
+	if !d.At.IsZero() {
+		deprecation = "@" + strconv.FormatInt(d.At.Unix(), 10)
+	}
+
+	return func(handler Handler) Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("Deprecation", deprecation)
+			if !d.Sunset.IsZero() {
+				w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Link != "" {
+				w.Header().Add("Link", "<"+d.Link+`>; rel="deprecation"; type="text/html"`)
+			}
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+// RouteInfo is a route registered by App.Handle or Version.Handle
+type RouteInfo struct {
+	Method string
+	// Path is the full pattern of the route including its version and group, e.g. /v1/wishlists/{id}
+	Path    string
+	Version string
+	Group   string
+	Doc     RouteDoc
+	// Deprecation is the deprecation of the route or of its version, nil if it is not deprecated
+	Deprecation *Deprecation
+}
+
+// SuccessStatus returns the status code of a successful response of the route
+func (ri RouteInfo) SuccessStatus() int {
+	if ri.Doc.Status == 0 {
+		return http.StatusOK
+	}
+	return ri.Doc.Status
+}
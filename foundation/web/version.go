@@ -0,0 +1,169 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// VersionConfig describes a version of the API
+type VersionConfig struct {
+	// Middlewares run after the app middlewares and before the ones of the route
+	Middlewares []Middleware
+	// Deprecation is sent on every route of the version unless the route has its own
+	Deprecation *Deprecation
+}
+
+// Version registers routes under the path prefix of a version of the API, e.g. /v1/users/login.
+// Routes are also reachable without the prefix, the version is then picked from the Accept
+// header, e.g. application/vnd.wishlist.v2+json for the vendor of the app or
+// application/json; version=v2, or is the default version of the app.
+type Version struct {
+	app  *App
+	name string
+	cfg  VersionConfig
+}
+
+// Router registers routes, it is implemented by App for unversioned routes and by Version
+type Router interface {
+	Handle(method, group, path string, handler Handler, opts ...RouteOption)
+}
+
+// Version creates the version with the name used in paths and Accept headers, e.g. v1.
+// Creating a version twice panics.
+func (app *App) Version(name string, cfg VersionConfig) *Version {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if slices.Contains(app.versions, name) {
+		panic(fmt.Sprintf("web: version %q is already created", name))
+	}
+	app.versions = append(app.versions, name)
+
+	return &Version{app: app, name: name, cfg: cfg}
+}
+
+// Name returns the name of the version, e.g. v1
+func (v *Version) Name() string {
+	return v.name
+}
+
+// Handle registers the handler for the method on /version/group/path and makes it the
+// handler of the version for /group/path, see App.Handle.
+func (v *Version) Handle(method, group, path string, handler Handler, opts ...RouteOption) {
+	rc := newRouteConfig(opts)
+	if rc.deprecation == nil {
+		rc.deprecation = v.cfg.Deprecation
+	}
+
+	handler = rc.wrap(handler)
+	handler = applyMiddlewares(handler, v.cfg.Middlewares)
+	handler = v.versionHeader(handler)
+
+	route := groupPath(group, path)
+	finalPath := "/" + v.name + route
+	v.app.mount(method, finalPath, applyMiddlewares(handler, v.app.mw))
+	v.app.addInfo(RouteInfo{Method: method, Path: finalPath, Version: v.name, Group: group, Doc: rc.doc, Deprecation: rc.deprecation})
+
+	v.app.alias(method, route, v.name, handler)
+}
+
+// versionHeader tells the client which version answered, unversioned paths are answered
+// by the default version when the client does not ask for one
+func (v *Version) versionHeader(handler Handler) Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("API-Version", v.name)
+		return handler(ctx, w, r)
+	}
+}
+
+// alias adds the handler of the version to the unversioned path, the path is mounted with
+// the first version registering it
+func (app *App) alias(method, route, version string, handler Handler) {
+	key := method + " " + route
+
+	app.mu.Lock()
+	handlers, mounted := app.aliases[key]
+	if !mounted {
+		handlers = make(map[string]Handler)
+		app.aliases[key] = handlers
+	}
+	handlers[version] = handler
+	app.mu.Unlock()
+
+	if !mounted {
+		app.mount(method, route, applyMiddlewares(app.dispatch(key), app.mw))
+	}
+}
+
+// dispatch picks the handler of the version the request asks for
+func (app *App) dispatch(key string) Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Add("Vary", "Accept")
+
+		version := requestedVersion(r.Header.Get("Accept"), app.vendor)
+		if version == "" {
+			version = app.defaultVersion
+		}
+
+		app.mu.RLock()
+		handler, ok := app.aliases[key][version]
+		known := slices.Contains(app.versions, version)
+		versions := slices.Clone(app.versions)
+		app.mu.RUnlock()
+
+		switch {
+		case ok:
+			return handler(ctx, w, r)
+		case !known:
+			return EndUserError{
+				Message: fmt.Sprintf("Accept header should ask for one of the versions %s", strings.Join(versions, ", ")),
+				Code:    "request.unsupported_version",
+				Status:  http.StatusNotAcceptable,
+				Params:  []string{strings.Join(versions, ", ")},
+			}
+		default:
+			return EndUserError{
+				Message: fmt.Sprintf("the route is not available in version %s", version),
+				Code:    "request.route_not_in_version",
+				Status:  http.StatusNotFound,
+				Params:  []string{version},
+			}
+		}
+	}
+}
+
+// vendorVersion matches the version segment of vendor media types, e.g. v2
+var vendorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// requestedVersion returns the version of the first media range of the Accept header naming
+// one, either as a media type of the vendor like application/vnd.wishlist.v2+json or as a
+// version parameter like application/json; version=v2. Media types of other vendors are
+// not versions of the API, e.g. application/vnd.ms-excel.sheet.
+func requestedVersion(accept, vendor string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		if version := params["version"]; version != "" {
+			return version
+		}
+
+		if vendor == "" {
+			continue
+		}
+		if version, ok := strings.CutPrefix(mediaType, "application/vnd."+vendor+"."); ok {
+			version, _, _ = strings.Cut(version, "+")
+			if vendorVersion.MatchString(version) {
+				return version
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestVersion(t *testing.T) {
+	// Answers end user errors like the errors middleware of the business layer
+	problems := Middleware(func(handler Handler) Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			err := handler(ctx, w, r)
+			var eue EndUserError
+			if errors.As(err, &eue) {
+				return RespondProblem(w, ctx, Problem{Status: eue.Status, Code: eue.Code, Detail: eue.Message})
+			}
+			return err
+		}
+	})
+
+	app := NewApp(zap.NewNop().Sugar(), http.NewServeMux(), []Middleware{problems}, shutdown, nil, WithDefaultVersion("v1"), WithVendor("wishlist"))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	answer := func(name string) Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("X-Answered-By", name)
+			return Respond(w, ctx, nil, http.StatusNoContent)
+		}
+	}
+
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	deprecatedAt := time.Date(2029, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var versionMW []string
+	v1 := app.Version("v1", VersionConfig{
+		Deprecation: &Deprecation{At: deprecatedAt, Sunset: sunset, Link: "https://example.com/migrate"},
+		Middlewares: []Middleware{func(handler Handler) Handler {
+			return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				versionMW = append(versionMW, "v1")
+				return handler(ctx, w, r)
+			}
+		}},
+	})
+	v2 := app.Version("v2", VersionConfig{})
+
+	v1.Handle(http.MethodGet, "items", "/{id}", answer("v1"))
+	v1.Handle(http.MethodGet, "items", "/legacy", answer("v1-legacy"))
+	v2.Handle(http.MethodGet, "items", "/{id}", answer("v2"), Deprecation{Sunset: sunset})
+
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		status      int
+		answeredBy  string
+		deprecation string
+		code        string
+	}{
+		{name: "prefixV1", path: "/v1/items/1", status: http.StatusNoContent, answeredBy: "v1", deprecation: "@1861920000"},
+		{name: "prefixV2", path: "/v2/items/1", status: http.StatusNoContent, answeredBy: "v2", deprecation: "true"},
+		{name: "default", path: "/items/1", status: http.StatusNoContent, answeredBy: "v1", deprecation: "@1861920000"},
+		{name: "vendorAccept", path: "/items/1", accept: "application/vnd.wishlist.v2+json", status: http.StatusNoContent, answeredBy: "v2", deprecation: "true"},
+		{name: "paramAccept", path: "/items/1", accept: "text/html, application/json; version=v2", status: http.StatusNoContent, answeredBy: "v2", deprecation: "true"},
+		{name: "otherVendorAccept", path: "/items/1", accept: "application/vnd.ms-excel.sheet", status: http.StatusNoContent, answeredBy: "v1", deprecation: "@1861920000"},
+		{name: "unknownVersion", path: "/items/1", accept: "application/vnd.wishlist.v9+json", status: http.StatusNotAcceptable, code: "request.unsupported_version"},
+		{name: "notInVersion", path: "/items/legacy", accept: "application/vnd.wishlist.v2+json", status: http.StatusNotFound, code: "request.route_not_in_version"},
+		{name: "prefixParam", path: "/v2/items/legacy", status: http.StatusNoContent, answeredBy: "v2", deprecation: "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("create request: %s", err)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("should be able to call handler over http: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status want %d got %d", tt.status, resp.StatusCode)
+			}
+			if got := resp.Header.Get("X-Answered-By"); got != tt.answeredBy {
+				t.Errorf("should be answered by %q, got %q", tt.answeredBy, got)
+			}
+			if got := resp.Header.Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation want %q got %q", tt.deprecation, got)
+			}
+			if tt.deprecation != "" && resp.Header.Get("Sunset") != "Tue, 01 Jan 2030 00:00:00 GMT" {
+				t.Errorf("should announce the sunset, got %q", resp.Header.Get("Sunset"))
+			}
+			if tt.code != "" {
+				var p Problem
+				if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+					t.Fatalf("decode problem: %s", err)
+				}
+				if p.Code != tt.code {
+					t.Errorf("code want %q got %q", tt.code, p.Code)
+				}
+			}
+		})
+	}
+
+	if len(versionMW) != 3 {
+		t.Errorf("version middlewares should run for v1 requests only, ran %d times", len(versionMW))
+	}
+
+	routes := app.Routes()
+	if len(routes) != 3 || routes[0].Path != "/v1/items/{id}" || routes[0].Version != "v1" || routes[0].Deprecation == nil {
+		t.Errorf("should list versioned routes, got %+v", routes)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic when creating a version twice")
+		}
+	}()
+	app.Version("v1", VersionConfig{})
+}
+
+func TestRequestedVersion(t *testing.T) {
+	tests := []struct {
+		accept  string
+		vendor  string
+		version string
+	}{
+		{accept: "", vendor: "wishlist", version: ""},
+		{accept: "application/json", vendor: "wishlist", version: ""},
+		{accept: "application/vnd.wishlist.v2+json", vendor: "wishlist", version: "v2"},
+		{accept: "application/vnd.wishlist.v2", vendor: "wishlist", version: "v2"},
+		{accept: "application/vnd.wishlist+json", vendor: "wishlist", version: ""},
+		{accept: "application/vnd.wishlist.beta+json", vendor: "wishlist", version: ""},
+		{accept: "application/vnd.wishlist.v2+json", vendor: "", version: ""},
+		{accept: "application/json;version=v3, application/vnd.wishlist.v2+json", vendor: "wishlist", version: "v3"},
+		{accept: "application/vnd.ms-excel.sheet, application/vnd.wishlist.v1+json", vendor: "wishlist", version: "v1"},
+		{accept: "application/vnd.ms-excel.sheet", vendor: "wishlist", version: ""},
+		{accept: "*/*, application/vnd.acme.shop.v1+json;q=0.5", vendor: "wishlist", version: ""},
+		{accept: "*/*, application/vnd.acme.shop.v1+json;q=0.5", vendor: "acme.shop", version: "v1"},
+		{accept: "application/json;version=v3", vendor: "", version: "v3"},
+	}
+
+	for _, tt := range tests {
+		if got := requestedVersion(tt.accept, tt.vendor); got != tt.version {
+			t.Errorf("requestedVersion(%q, %q) want %q got %q", tt.accept, tt.vendor, tt.version, got)
+		}
+	}
+}
@@ -5,15 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
@@ -28,16 +31,68 @@ type App struct {
 	mw       []Middleware
 	shutdown chan os.Signal
 	tracer   trace.Tracer
+
+	mu     sync.RWMutex
+	routes map[string][]string
+	infos  []RouteInfo
+	// options holds the OPTIONS handlers registered by Handle, the automatic OPTIONS route
+	// of their path hands requests to them, both are keyed by routeKey
+	options map[string]Handler
+
+	// versions are the names of the versions created by Version, aliases holds the handlers
+	// of unversioned paths by method and path then version, see App.dispatch
+	versions       []string
+	aliases        map[string]map[string]Handler
+	defaultVersion string
+	vendor         string
+
+	compressMin int
+}
+
+// Option configures optional behaviour of the App
+type Option func(*App)
+
+// WithCompressionThreshold sets the minimum size of response bodies compressed by Respond,
+// a negative size disables compression
+func WithCompressionThreshold(size int) Option {
+	return func(app *App) {
+		app.compressMin = size
+	}
+}
+
+// WithDefaultVersion serves requests to unversioned paths that do not ask for a version in
+// their Accept header by the version, see App.Version
+func WithDefaultVersion(name string) Option {
+	return func(app *App) {
+		app.defaultVersion = name
+	}
+}
+
+// WithVendor lets requests to unversioned paths ask for a version by the vendor media type
+// of the API in their Accept header, e.g. application/vnd.wishlist.v2+json for the vendor
+// wishlist. Without it only the version parameter of media types is read.
+func WithVendor(name string) Option {
+	return func(app *App) {
+		app.vendor = name
+	}
 }
 
-func NewApp(log *zap.SugaredLogger, mux *http.ServeMux, mw []Middleware, shutdown chan os.Signal, tracer trace.Tracer) *App {
-	return &App{
-		log:      log,
-		mux:      mux,
-		mw:       mw,
-		shutdown: shutdown,
-		tracer:   tracer,
+func NewApp(log *zap.SugaredLogger, mux *http.ServeMux, mw []Middleware, shutdown chan os.Signal, tracer trace.Tracer, opts ...Option) *App {
+	app := &App{
+		log:         log,
+		mux:         mux,
+		mw:          mw,
+		shutdown:    shutdown,
+		tracer:      tracer,
+		routes:      make(map[string][]string),
+		options:     make(map[string]Handler),
+		aliases:     make(map[string]map[string]Handler),
+		compressMin: defaultCompressMin,
+	}
+	for _, opt := range opts {
+		opt(app)
 	}
+	return app
 }
 
 func applyMiddlewares(handler Handler, mw []Middleware) Handler {
@@ -59,76 +114,235 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	app.mux.ServeHTTP(w, r)
 }
 
-func (app *App) Handle(method, group, path string, handler Handler, mw ...Middleware) {
-	handler = applyMiddlewares(handler, mw)
-	handler = applyMiddlewares(handler, app.mw)
+// Handle registers the handler for the method on /group/path, path may contain parameters
+// in the form of {name} which are accessible by Param. Registering a route that conflicts
+// with an already registered one panics.
+// Every path also gets an OPTIONS route passing through the app middlewares, which answers
+// with the allowed methods unless a middleware, e.g. CORS, answers it first or an OPTIONS
+// handler is registered for the path.
+// Options are the middlewares of the route, its Deprecation and its RouteDoc.
+func (app *App) Handle(method, group, path string, handler Handler, opts ...RouteOption) {
+	rc := newRouteConfig(opts)
+	handler = rc.wrap(handler)
+
+	finalPath := groupPath(group, path)
+	app.mount(method, finalPath, applyMiddlewares(handler, app.mw))
+	app.addInfo(RouteInfo{Method: method, Path: finalPath, Group: group, Doc: rc.doc, Deprecation: rc.deprecation})
+}
+
+func groupPath(group, path string) string {
+	if group == "" {
+		return path
+	}
+	return "/" + group + path
+}
+
+// mount registers the handler and the OPTIONS route of its path, the handler should
+// already be wrapped by the app middlewares. Paths differing only by the names of their
+// parameters share the OPTIONS route, it passes requests to the OPTIONS handler registered
+// for the path if any, such a handler sees the parameter names of the first route of the path.
+func (app *App) mount(method, path string, handler Handler) {
+	key := routeKey(path)
+
+	if method != http.MethodOptions {
+		app.handle(method, path, handler)
+	}
+
+	app.mu.Lock()
+	methods, registered := app.routes[key]
+	if method == http.MethodOptions {
+		if _, ok := app.options[key]; ok {
+			app.mu.Unlock()
+			panic(fmt.Sprintf("web: register route %q: OPTIONS is already registered", method+" "+path))
+		}
+		app.options[key] = handler
+	}
+	app.routes[key] = append(methods, method)
+	app.mu.Unlock()
+
+	if !registered {
+		app.handle(http.MethodOptions, path, app.optionsRoute(key))
+	}
+}
+
+// routeKey strips the names of the path parameters, ServeMux treats patterns differing
+// only by them as the same route
+func routeKey(path string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			b.WriteString(path)
+			return b.String()
+		}
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			b.WriteString(path)
+			return b.String()
+		}
+		b.WriteString(path[:start+1])
+		if strings.HasSuffix(path[start:start+end], "...") {
+			b.WriteString("...")
+		}
+		b.WriteByte('}')
+		path = path[start+end+1:]
+	}
+}
+
+// optionsRoute answers OPTIONS requests by the handler registered for the route or by the
+// allowed methods
+func (app *App) optionsRoute(key string) Handler {
+	auto := applyMiddlewares(app.allowed(key), app.mw)
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		app.mu.RLock()
+		handler, ok := app.options[key]
+		app.mu.RUnlock()
+
+		if ok {
+			return handler(ctx, w, r)
+		}
+		return auto(ctx, w, r)
+	}
+}
+
+func (app *App) addInfo(info RouteInfo) {
+	app.mu.Lock()
+	app.infos = append(app.infos, info)
+	app.mu.Unlock()
+}
 
+func (app *App) handle(method, route string, handler Handler) {
 	h := func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := app.startSpan(w, r)
+		ctx, span := app.startSpan(w, r, route)
 		defer span.End()
 
 		v := Values{
 			TraceID: span.SpanContext().TraceID().String(),
 			Tracer:  app.tracer,
 			Now:     time.Now().UTC(),
-		}
-		ctx = setValues(ctx, &v)
+			Route:   route,
 
-		if r.Method != method {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
+			request:     r,
+			compressMin: app.compressMin,
 		}
+		ctx = setValues(ctx, &v)
 
-		if err := handler(ctx, w, r); err != nil {
-			// lost integrity, shut down the app
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			if _, err := w.Write([]byte("something went really wrong")); err != nil {
+		err := handler(ctx, w, r)
+		if err != nil {
+			if IsShutdown(err) {
+				app.log.Errorw("lost integrity, shutting down", "traceID", v.TraceID, "ERROR", err)
+				// Errors middlewares answer before returning the error, only answer if none did
+				if v.StatusCode == 0 {
+					app.respondInternal(w, v.TraceID)
+				}
+				endSpan(span, http.StatusInternalServerError, err)
 				app.shutServerDown()
 				return
 			}
-			app.shutServerDown()
-			return
+
+			app.log.Errorw("unhandled error", "traceID", v.TraceID, "ERROR", err)
+			// The error can only be logged once the response has been sent
+			if v.StatusCode == 0 {
+				app.respondInternal(w, v.TraceID)
+				v.StatusCode = http.StatusInternalServerError
+			}
 		}
+		endSpan(span, v.StatusCode, err)
 	}
 
-	finalPath := path
-	if group != "" {
-		finalPath = "/" + group + path
-	}
+	// ServeMux answers requests with a registered path but another method by 405 and an Allow header
+	pattern := method + " " + route
+	defer func() {
+		if r := recover(); r != nil {
+			panic(fmt.Sprintf("web: register route %q: %v", pattern, r))
+		}
+	}()
+	app.mux.HandleFunc(pattern, h)
+}
 
-	app.mux.HandleFunc(finalPath, h)
+// allowed answers OPTIONS requests with the methods registered for the route
+func (app *App) allowed(key string) Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Allow", strings.Join(app.Methods(key), ", "))
+		return Respond(w, ctx, nil, http.StatusNoContent)
+	}
 }
 
-func Respond(w http.ResponseWriter, ctx context.Context, data any, statusCode int) error {
-	SetStatusCode(ctx, statusCode)
-	if statusCode == http.StatusNoContent || data == nil {
-		w.WriteHeader(statusCode)
-		return nil
+// Methods returns the methods registered for the route, including OPTIONS, parameter names
+// of the route do not matter
+func (app *App) Methods(route string) []string {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	methods := append([]string{}, app.routes[routeKey(route)]...)
+	if len(methods) != 0 && !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
 	}
+	return methods
+}
+
+// Routes returns the routes registered by Handle in the order of registration, the OPTIONS
+// routes added for every path are left out
+func (app *App) Routes() []RouteInfo {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	return slices.Clone(app.infos)
+}
+
+// Param returns the value of the path parameter with the name used in the route pattern,
+// e.g. "id" for "/wishlists/{id}", or an empty string if there is no such parameter
+func Param(r *http.Request, name string) string {
+	return r.PathValue(name)
+}
 
-	jsn, err := json.Marshal(data)
+// respondInternal is the last resort response for errors that escaped every middleware,
+// the trace id lets the client report the failure
+func (app *App) respondInternal(w http.ResponseWriter, traceID string) {
+	jsn, err := json.Marshal(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusInternalServerError),
+		Status:   http.StatusInternalServerError,
+		Detail:   "something went really wrong",
+		Instance: traceID,
+		Code:     CodeInternal,
+	})
 	if err != nil {
-		return fmt.Errorf("marshal response: %w", err)
+		app.log.Errorw("respond internal error", "traceID", traceID, "ERROR", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
+	w.Header().Set("Content-Type", MediaTypeProblem)
+	w.WriteHeader(http.StatusInternalServerError)
 	if _, err := w.Write(jsn); err != nil {
-		return err
+		app.log.Errorw("respond internal error", "traceID", traceID, "ERROR", err)
 	}
-	return nil
 }
 
-func (app *App) startSpan(w http.ResponseWriter, r *http.Request) (context.Context, trace.Span) {
-	ctx := r.Context()
+// startSpan continues the trace of the caller extracted from the request headers by the
+// configured propagator, the span is named after the method and the route pattern
+func (app *App) startSpan(w http.ResponseWriter, r *http.Request, route string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
 
 	span := trace.SpanFromContext(ctx)
 
 	if app.tracer != nil {
-		ctx, span = app.tracer.Start(ctx, "pkg.web.handle")
-		span.SetAttributes(attribute.String("endpoint", r.RequestURI))
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		ctx, span = app.tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				semconv.URLScheme(scheme),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
 	}
 
 	// Inject the trace information into the response.
@@ -137,26 +351,19 @@ func (app *App) startSpan(w http.ResponseWriter, r *http.Request) (context.Conte
 	return ctx, span
 }
 
-type validator interface {
-	Validate() error
-}
-
-func DecodeBody(body io.ReadCloser, dst any) error {
-	dec := json.NewDecoder(body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(dst); err != nil {
-		return EndUserError{
-			Message: "request body is malformed",
-			Status:  http.StatusBadRequest,
-		}
+// endSpan records the final status code, only server errors mark the span as failed as
+// client errors are a valid outcome for the server
+func endSpan(span trace.Span, statusCode int, err error) {
+	if statusCode == 0 {
+		statusCode = http.StatusOK
 	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
 
-	v, ok := dst.(validator)
-	if ok {
-		if err := v.Validate(); err != nil {
-			return fmt.Errorf("validation: %w", err)
+	if statusCode >= http.StatusInternalServerError {
+		desc := http.StatusText(statusCode)
+		if err != nil {
+			desc = err.Error()
 		}
+		span.SetStatus(codes.Error, desc)
 	}
-
-	return nil
 }
@@ -5,12 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 )
 
@@ -37,12 +45,12 @@ func TestHandle(t *testing.T) {
 		OK bool `json:"ok"`
 	}
 
-	h := func(w http.ResponseWriter, r *http.Request, ctx context.Context) error {
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		d := data{OK: true}
 		return Respond(w, ctx, d, http.StatusOK)
 	}
 
-	app.Handle(http.MethodGet, "testgrp", "/testpath", h, nil)
+	app.Handle(http.MethodGet, "testgrp", "/testpath", h)
 
 	getResp, err := http.Get(fmt.Sprintf("%s/testgrp/testpath", url))
 	if err != nil {
@@ -68,30 +76,161 @@ func TestHandle(t *testing.T) {
 	if postResp.StatusCode != http.StatusMethodNotAllowed {
 		t.Fatalf("should return 405 on wrong method calls, status code: %d", postResp.StatusCode)
 	}
+	if allow := postResp.Header.Get("Allow"); !strings.Contains(allow, http.MethodGet) {
+		t.Errorf("should list allowed methods in Allow header, got: %q", allow)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	app, url, close := runApp(t)
+	defer close()
+
+	respond := func(body string) Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return Respond(w, ctx, body+Param(r, "id"), http.StatusOK)
+		}
+	}
+
+	// Same path with different methods should not overwrite each other
+	app.Handle(http.MethodGet, "wishlists", "/{id}", respond("get "))
+	app.Handle(http.MethodDelete, "wishlists", "/{id}", respond("delete "))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{method: http.MethodGet, path: "/wishlists/12", status: http.StatusOK, body: "get 12"},
+		{method: http.MethodDelete, path: "/wishlists/7", status: http.StatusOK, body: "delete 7"},
+		{method: http.MethodPut, path: "/wishlists/7", status: http.StatusMethodNotAllowed},
+		{method: http.MethodOptions, path: "/wishlists/7", status: http.StatusNoContent},
+		{method: http.MethodGet, path: "/wishlists/7/products", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, url+tt.path, nil)
+			if err != nil {
+				t.Fatalf("create request: %s", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("should be able to call handler over http: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status want %d got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status == http.StatusMethodNotAllowed || tt.method == http.MethodOptions {
+				allow := resp.Header.Get("Allow")
+				if !strings.Contains(allow, http.MethodGet) || !strings.Contains(allow, http.MethodDelete) {
+					t.Errorf("should list allowed methods in Allow header, got: %q", allow)
+				}
+			}
+			if tt.body != "" {
+				var body string
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("should be able to decode response: %s", err)
+				}
+				if body != tt.body {
+					t.Errorf("body want %q got %q", tt.body, body)
+				}
+			}
+		})
+	}
+
+	t.Run("conflict", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("should panic when registering a conflicting route")
+			}
+		}()
+		app.Handle(http.MethodGet, "wishlists", "/{wishlistID}", respond(""))
+	})
+}
+
+func TestOptionsRoute(t *testing.T) {
+	app, url, close := runApp(t)
+	defer close()
+
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return Respond(w, ctx, nil, http.StatusNoContent)
+	}
+
+	// Same shape with other parameter names shares the automatic OPTIONS route
+	app.Handle(http.MethodGet, "wishlists", "/{id}", ok)
+	app.Handle(http.MethodDelete, "wishlists", "/{wid}", ok)
+
+	// OPTIONS registered after the automatic route replaces it
+	app.Handle(http.MethodGet, "items", "/{id}", ok)
+	app.Handle(http.MethodOptions, "items", "/{itemID}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("X-Custom", "items")
+		return Respond(w, ctx, nil, http.StatusOK)
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		allow  []string
+		custom string
+	}{
+		{path: "/wishlists/1", status: http.StatusNoContent, allow: []string{http.MethodGet, http.MethodDelete, http.MethodOptions}},
+		{path: "/items/1", status: http.StatusOK, custom: "items"},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodOptions, url+tt.path, nil)
+		if err != nil {
+			t.Fatalf("create request: %s", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("should be able to call handler over http: %s", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status want %d got %d", tt.path, tt.status, resp.StatusCode)
+		}
+		for _, method := range tt.allow {
+			if !strings.Contains(resp.Header.Get("Allow"), method) {
+				t.Errorf("%s: Allow should list %s, got: %q", tt.path, method, resp.Header.Get("Allow"))
+			}
+		}
+		if got := resp.Header.Get("X-Custom"); got != tt.custom {
+			t.Errorf("%s: should be answered by the registered OPTIONS handler, got: %q", tt.path, got)
+		}
+	}
+
+	if got := app.Methods("/items/{x}"); len(got) != 2 {
+		t.Errorf("should list methods regardless of parameter names, got: %v", got)
+	}
 }
 
 func TestMiddleware(t *testing.T) {
 	const key = "factor"
 
-	// sets factor in context
-	handlerMW := func(handler Handler) Handler {
-		return func(w http.ResponseWriter, r *http.Request, ctx context.Context) error {
+	// sets factor in context, app middlewares wrap the handler middlewares
+	appMW := func(handler Handler) Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			ctx = context.WithValue(ctx, key, 0)
-			return handler(w, r, ctx)
+			return handler(ctx, w, r)
 		}
 	}
 
 	// increases factor
-	appMW := func(handler Handler) Handler {
-		return func(w http.ResponseWriter, r *http.Request, ctx context.Context) error {
+	handlerMW := Middleware(func(handler Handler) Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			f := ctx.Value(key).(int)
 			ctx = context.WithValue(ctx, key, f+1)
-			return handler(w, r, ctx)
+			return handler(ctx, w, r)
 		}
-	}
+	})
 
 	// increases factor one time
-	h := func(w http.ResponseWriter, r *http.Request, ctx context.Context) error {
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		f := ctx.Value(key).(int)
 		if f != 1 {
 			t.Errorf("context should have factor with value 1, but is: %d", f)
@@ -119,11 +258,25 @@ func TestError(t *testing.T) {
 	app, url, close := runApp(t)
 	defer close()
 
-	h := func(w http.ResponseWriter, r *http.Request, ctx context.Context) error {
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		return errors.New("bad things happened")
 	}
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return Respond(w, ctx, nil, http.StatusNoContent)
+	}
 
-	app.Handle(http.MethodPost, "testgrp", "/testerr", h, nil)
+	sent := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		SetStatusCode(ctx, http.StatusOK)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("partial")); err != nil {
+			return err
+		}
+		return errors.New("failed after responding")
+	}
+
+	app.Handle(http.MethodPost, "testgrp", "/testerr", h)
+	app.Handle(http.MethodGet, "testgrp", "/testok", ok)
+	app.Handle(http.MethodGet, "testgrp", "/testsent", sent)
 
 	resp, err := http.Post(fmt.Sprintf("%s/testgrp/testerr", url), "application/json", nil)
 	if err != nil {
@@ -134,12 +287,165 @@ func TestError(t *testing.T) {
 	if resp.StatusCode != http.StatusInternalServerError {
 		t.Fatalf("should have 500 status code, has: %s", resp.Status)
 	}
+	if ct := resp.Header.Get("Content-Type"); ct != MediaTypeProblem {
+		t.Errorf("should respond with problem details, content type: %s", ct)
+	}
+
+	var body struct {
+		TraceID string `json:"instance"`
+		Code    string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("should be able to decode response: %s", err)
+	}
+	if body.TraceID == "" {
+		t.Error("response should carry the trace id")
+	}
+	if body.Code != CodeInternal {
+		t.Errorf("code want %q got %q", CodeInternal, body.Code)
+	}
+
+	timer := time.NewTimer(300 * time.Millisecond)
+	select {
+	case <-shutdown:
+		t.Fatal("should not receive shutdown signal for a regular error")
+	case <-timer.C:
+	}
+
+	sentResp, err := http.Get(fmt.Sprintf("%s/testgrp/testsent", url))
+	if err != nil {
+		t.Fatalf("should be able to call handler over http: %s", err)
+	}
+	defer sentResp.Body.Close()
+	sentBody, err := io.ReadAll(sentResp.Body)
+	if err != nil {
+		t.Fatalf("should be able to read response: %s", err)
+	}
+	if sentResp.StatusCode != http.StatusOK || string(sentBody) != "partial" {
+		t.Errorf("errors after responding should not change the response, got: %s %s", sentResp.Status, sentBody)
+	}
+
+	// The server should keep serving after a buggy handler
+	okResp, err := http.Get(fmt.Sprintf("%s/testgrp/testok", url))
+	if err != nil {
+		t.Fatalf("should be able to call handler over http: %s", err)
+	}
+	defer okResp.Body.Close()
+	if okResp.StatusCode != http.StatusNoContent {
+		t.Fatalf("should have 204 status code, has: %s", okResp.Status)
+	}
+}
+
+func TestShutdownError(t *testing.T) {
+	app, url, close := runApp(t)
+	defer close()
+
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("handler: %w", NewShutdownError("integrity lost"))
+	}
+
+	app.Handle(http.MethodPost, "testgrp", "/testshutdown", h)
+
+	resp, err := http.Post(fmt.Sprintf("%s/testgrp/testshutdown", url), "application/json", nil)
+	if err != nil {
+		t.Fatalf("should be able to call handler over http: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("should have 500 status code, has: %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != MediaTypeProblem {
+		t.Errorf("should respond with problem details, content type: %s", ct)
+	}
+	var p Problem
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatalf("should be able to decode response: %s", err)
+	}
+	if p.Status != http.StatusInternalServerError || p.Instance == "" {
+		t.Errorf("problem should have the status and the trace id, got: %+v", p)
+	}
 
 	timer := time.NewTimer(300 * time.Millisecond)
 	select {
 	case <-shutdown:
 		t.Log("shutdown signal received")
 	case <-timer.C:
-		t.Fatalf("should have received shutdown signal but timeoput reached")
+		t.Fatalf("should have received shutdown signal but timeout reached")
+	}
+}
+
+func TestTracing(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	log, err := zap.NewProduction()
+	if err != nil {
+		t.Fatalf("should be able to create a logger: %s", err)
+	}
+	app := NewApp(log.Sugar(), http.NewServeMux(), nil, shutdown, tp.Tracer("test"))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	app.Handle(http.MethodGet, "traces", "/{id}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if Param(r, "id") == "fail" {
+			return errors.New("failed")
+		}
+		return Respond(w, ctx, nil, http.StatusNotFound)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	for _, id := range []string{"missing", "fail"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/traces/"+id, nil)
+		if err != nil {
+			t.Fatalf("create request: %s", err)
+		}
+		req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		req.Header.Set("User-Agent", "tracing-test")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("should be able to call handler over http: %s", err)
+		}
+		resp.Body.Close()
+		if !strings.Contains(resp.Header.Get("traceparent"), traceID) {
+			t.Errorf("response should carry the incoming trace, got: %q", resp.Header.Get("traceparent"))
+		}
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("should record a span per request, got: %d", len(spans))
+	}
+
+	for i, want := range []struct {
+		status int
+		code   codes.Code
+	}{
+		{status: http.StatusNotFound, code: codes.Unset},
+		{status: http.StatusInternalServerError, code: codes.Error},
+	} {
+		span := spans[i]
+		if span.Name() != "GET /traces/{id}" {
+			t.Errorf("span should be named by method and route, got: %q", span.Name())
+		}
+		if span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+			t.Errorf("span should join the incoming trace, got trace %s parent %s", span.SpanContext().TraceID(), span.Parent().SpanID())
+		}
+		if span.Status().Code != want.code {
+			t.Errorf("span status want %v got %v", want.code, span.Status())
+		}
+
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, attr := range span.Attributes() {
+			attrs[attr.Key] = attr.Value
+		}
+		if attrs["http.route"].AsString() != "/traces/{id}" || attrs["user_agent.original"].AsString() != "tracing-test" {
+			t.Errorf("span should have route and user agent attributes, got: %v", span.Attributes())
+		}
+		if got := attrs["http.response.status_code"].AsInt64(); got != int64(want.status) {
+			t.Errorf("status code attribute want %d got %d", want.status, got)
+		}
 	}
 }